@@ -0,0 +1,74 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxActionLength is the length of the Action column of Entry.
+	MaxActionLength = 24
+	actionOptionKey = pluginName + ":action"
+)
+
+var (
+	ErrInvalidAction = errors.New("invalid history action")
+
+	_ RawActionHistory = (*RawActionEntry)(nil)
+)
+
+// RawActionEntry holds the column of RawActionHistory. Embed it next to Entry to record raw actions.
+type RawActionEntry struct {
+	RawAction Action `gorm:"type:varchar(24)"`
+}
+
+// WithRawActions makes the plugin keep the action of the statement, e.g. ActionUpdate, in the RawAction column
+// of the history entries when it is overridden by SetAction. History models must implement RawActionHistory.
+func WithRawActions() ConfigFunc {
+	return func(c *Config) {
+		c.RawActions = true
+	}
+}
+
+// SetAction makes the history entries of the statements run through the returned db be recorded with action
+// instead of the action of the statement, e.g. Action("approve") instead of ActionUpdate. Like ExpectVersion, the
+// action is not propagated through the context, so the associations saved along with the record keep their
+// own actions. An empty action or one longer than MaxActionLength makes the statement fail with ErrInvalidAction.
+func SetAction(db *gorm.DB, action Action) *gorm.DB {
+	db = db.Set(actionOptionKey, action)
+	if err := action.Validate(); err != nil {
+		_ = db.AddError(err)
+	}
+
+	return db
+}
+
+func GetAction(db *gorm.DB) (Action, bool) {
+	value, ok := db.Get(actionOptionKey)
+	if !ok {
+		return "", false
+	}
+
+	action, ok := value.(Action)
+
+	return action, ok
+}
+
+func (a Action) Validate() error {
+	if a == "" {
+		return fmt.Errorf("%w: action is empty", ErrInvalidAction)
+	}
+
+	if utf8.RuneCountInString(string(a)) > MaxActionLength {
+		return fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidAction, a, MaxActionLength)
+	}
+
+	return nil
+}
+
+func (e *RawActionEntry) SetHistoryRawAction(action Action) {
+	e.RawAction = action
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func (suite *PluginTestSuite) TestSetAction() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(SetAction(suite.db, "approve").Save(&p).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", p.ID).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(ActionCreate, entries[0].Action)
+	suite.Equal(Action("approve"), entries[1].Action)
+	suite.Zero(entries[1].RawAction)
+}
+
+func (suite *PluginTestSuite) TestSetActionWithRawActions() {
+	suite.Require().NoError(suite.db.Use(New(WithRawActions())))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(SetAction(suite.db, "import").Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal(Action("import"), entry.Action)
+	suite.Equal(ActionCreate, entry.RawAction)
+}
+
+func (suite *PluginTestSuite) TestSetActionInvalid() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	err := SetAction(suite.db, Action(strings.Repeat("a", MaxActionLength+1))).Create(&p).Error
+	suite.True(errors.Is(err, ErrInvalidAction))
+
+	err = SetAction(suite.db, "").Create(&p).Error
+	suite.True(errors.Is(err, ErrInvalidAction))
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&Person{}).Count(&count).Error)
+	suite.Zero(count)
+}
+
+func (suite *PluginTestSuite) TestSetActionMultiByte() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	action := Action(strings.Repeat("é", MaxActionLength))
+	suite.Require().NoError(action.Validate())
+	suite.True(errors.Is(Action(strings.Repeat("é", MaxActionLength+1)).Validate(), ErrInvalidAction))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(SetAction(suite.db, action).Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal(action, entry.Action)
+}
+
+func (suite *PluginTestSuite) TestInvalidActionDoesNotUseVersion() {
+	suite.Require().NoError(suite.db.Use(New(WithVersionFunc(NewSequentialVersion().Version))))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	err := suite.db.Set(actionOptionKey, Action("")).Save(&p).Error
+	suite.True(errors.Is(err, ErrInvalidAction))
+
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	latest, err := LatestVersion(suite.db, &p, p.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Version("0000000002"), latest)
+}
+
+func (suite *PluginTestSuite) TestFailedStatementIsNotRecorded() {
+	var stored []History
+	store := StoreFunc(func(db *gorm.DB, hs ...History) error {
+		stored = append(stored, hs...)
+
+		return nil
+	})
+	suite.Require().NoError(suite.db.Use(New(WithModel(&Person{}, WithStore(store)))))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+	suite.Require().Len(stored, 1)
+
+	duplicate := Person{Model: gorm.Model{ID: p.ID}, FirstName: "Jane"}
+	suite.Require().Error(suite.db.Create(&duplicate).Error)
+	suite.Len(stored, 1)
+}
@@ -0,0 +1,381 @@
+package history
+
+import (
+	"compress/gzip"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ArchiveFormatJSONL ArchiveFormat = "jsonl"
+	ArchiveFormatCSV   ArchiveFormat = "csv"
+
+	archiveManifestName = "manifest.json"
+	archiveDayLayout    = "2006-01-02"
+	archiveRunLayout    = "20060102T150405Z"
+)
+
+type (
+	ArchiveFormat string
+
+	// ArchiveStorage stores the archive files. Create replaces the object name if it exists, since the manifest of a
+	// run is rewritten after every batch, and the object must be complete once Close returns without error.
+	ArchiveStorage interface {
+		Create(name string) (io.WriteCloser, error)
+	}
+
+	DirStorage struct {
+		dir string
+	}
+
+	// dirFile is a file of DirStorage, written to a temporary file which is synced and renamed to name on Close, so
+	// that a crash never leaves a truncated archive file under its name.
+	dirFile struct {
+		*os.File
+		name string
+	}
+
+	ArchiveManifest struct {
+		CreatedAt time.Time     `json:"created_at"`
+		Format    ArchiveFormat `json:"format"`
+		Files     []ArchiveFile `json:"files"`
+	}
+
+	ArchiveFile struct {
+		Name   string `json:"name"`
+		Table  string `json:"table"`
+		Day    string `json:"day"`
+		Rows   int64  `json:"rows"`
+		Bytes  int64  `json:"bytes"`
+		SHA256 string `json:"sha256"`
+	}
+
+	archiveWriter struct {
+		file   ArchiveFile
+		dest   io.WriteCloser
+		hash   hash.Hash
+		gz     *gzip.Writer
+		csv    *csv.Writer
+		json   *json.Encoder
+		closed bool
+	}
+
+	// archiveRun writes the rows of one Prune or Compact run to the archive storage. Every batch is written to its
+	// own files, one per table and day, which are closed and recorded in the manifest before the batch is deleted.
+	archiveRun struct {
+		storage   ArchiveStorage
+		format    ArchiveFormat
+		name      string
+		createdAt time.Time
+		batches   int
+		files     []ArchiveFile
+	}
+
+	countWriter struct {
+		n *int64
+	}
+)
+
+func WithArchive(storage ArchiveStorage, format ArchiveFormat) ConfigFunc {
+	return func(c *Config) {
+		c.ArchiveStorage = storage
+		c.ArchiveFormat = format
+	}
+}
+
+func NewDirStorage(dir string) *DirStorage {
+	return &DirStorage{dir: dir}
+}
+
+func (s *DirStorage) Create(name string) (io.WriteCloser, error) {
+	name = filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirFile{File: f, name: name}, nil
+}
+
+func (f *dirFile) Close() error {
+	err := f.File.Sync()
+	if cerr := f.File.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.File.Name())
+
+		return err
+	}
+
+	if err := os.Chmod(f.File.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.File.Name(), f.name)
+}
+
+func (p *Plugin) newArchiveRun(now time.Time) (*archiveRun, error) {
+	suffix := make([]byte, 4)
+	if _, err := crand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	return &archiveRun{
+		storage:   p.archiveStorage,
+		format:    p.archiveFormat,
+		name:      fmt.Sprintf("%s-%s", now.UTC().Format(archiveRunLayout), hex.EncodeToString(suffix)),
+		createdAt: now,
+	}, nil
+}
+
+// Write writes the rows of s having ids to new files, one per table and day they were created in, closes them and
+// records them in the manifest of the run. The rows are archived once it returns without error. It returns the
+// number of rows written.
+func (r *archiveRun) Write(db *gorm.DB, s *schema.Schema, ids []interface{}) (n int64, err error) {
+	createdAtCol, err := lookUpColumn(s, "CreatedAt")
+	if err != nil {
+		return 0, err
+	}
+
+	pk := qualifiedColumn(db, s.Table, s.PrioritizedPrimaryField.DBName)
+	rows := reflect.New(reflect.SliceOf(s.ModelType))
+	err = db.
+		Unscoped().
+		Where(fmt.Sprintf("%s IN ?", pk), ids).
+		Order(pk).
+		Find(rows.Interface()).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	r.batches++
+	writers := make(map[string]*archiveWriter)
+	defer func() {
+		if err != nil {
+			for _, w := range writers {
+				_ = w.Close()
+			}
+		}
+	}()
+
+	rows = rows.Elem()
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		values := make(map[string]interface{}, len(s.DBNames))
+		for _, name := range s.DBNames {
+			values[name], _ = s.FieldsByDBName[name].ValueOf(db.Statement.Context, row)
+		}
+
+		day := "unknown"
+		if t, ok := values[createdAtCol].(time.Time); ok {
+			day = t.UTC().Format(archiveDayLayout)
+		}
+
+		w, ok := writers[day]
+		if !ok {
+			if w, err = r.newWriter(s, day); err != nil {
+				return n, err
+			}
+
+			writers[day] = w
+		}
+
+		if err := w.Write(values, s.DBNames); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	files := make([]ArchiveFile, 0, len(writers))
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			return n, err
+		}
+
+		files = append(files, w.file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	r.files = append(r.files, files...)
+
+	return n, r.writeManifest()
+}
+
+// Close writes the manifest of a run which archived no rows, so that every run has one.
+func (r *archiveRun) Close() error {
+	if r.batches > 0 {
+		return nil
+	}
+
+	return r.writeManifest()
+}
+
+// writeManifest replaces the manifest of the run with one describing the files written so far.
+func (r *archiveRun) writeManifest() error {
+	manifest := ArchiveManifest{
+		CreatedAt: r.createdAt,
+		Format:    r.format,
+		Files:     r.files,
+	}
+
+	w, err := r.storage.Create(path.Join(r.name, archiveManifestName))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		_ = w.Close()
+
+		return err
+	}
+
+	return w.Close()
+}
+
+func (r *archiveRun) newWriter(s *schema.Schema, day string) (*archiveWriter, error) {
+	name := path.Join(r.name, s.Table, fmt.Sprintf("%s.%04d.%s.gz", day, r.batches, r.format))
+	dest, err := r.storage.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &archiveWriter{
+		file: ArchiveFile{
+			Name:  name,
+			Table: s.Table,
+			Day:   day,
+		},
+		dest: dest,
+		hash: sha256.New(),
+	}
+	w.gz = gzip.NewWriter(io.MultiWriter(dest, w.hash, countWriter{n: &w.file.Bytes}))
+
+	switch r.format {
+	case ArchiveFormatJSONL:
+		w.json = json.NewEncoder(w.gz)
+	case ArchiveFormatCSV:
+		w.csv = csv.NewWriter(w.gz)
+		if err := w.csv.Write(s.DBNames); err != nil {
+			_ = w.Close()
+
+			return nil, err
+		}
+	default:
+		_ = w.Close()
+
+		return nil, fmt.Errorf("unsupported archive format %q", r.format)
+	}
+
+	return w, nil
+}
+
+func (w *archiveWriter) Write(values map[string]interface{}, columns []string) error {
+	w.file.Rows++
+
+	if w.json != nil {
+		return w.json.Encode(values)
+	}
+
+	record := make([]string, len(columns))
+	for i, name := range columns {
+		s, err := formatArchiveValue(values[name])
+		if err != nil {
+			return err
+		}
+
+		record[i] = s
+	}
+
+	return w.csv.Write(record)
+}
+
+func (w *archiveWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			_ = w.dest.Close()
+
+			return err
+		}
+	}
+
+	if err := w.gz.Close(); err != nil {
+		_ = w.dest.Close()
+
+		return err
+	}
+
+	w.file.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+
+	return w.dest.Close()
+}
+
+func (w countWriter) Write(p []byte) (int, error) {
+	*w.n += int64(len(p))
+
+	return len(p), nil
+}
+
+func formatArchiveValue(v interface{}) (string, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", nil
+		}
+
+		v = rv.Elem().Interface()
+	}
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return "", err
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
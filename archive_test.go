@@ -0,0 +1,255 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+type failingStorage struct{}
+
+func (failingStorage) Create(string) (io.WriteCloser, error) {
+	return nil, errors.New("storage is not available")
+}
+
+// limitedStorage fails once limit objects were created.
+type limitedStorage struct {
+	ArchiveStorage
+	limit int
+}
+
+func (s *limitedStorage) Create(name string) (io.WriteCloser, error) {
+	if s.limit == 0 {
+		return nil, errors.New("storage is full")
+	}
+
+	s.limit--
+
+	return s.ArchiveStorage.Create(name)
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveJSONL() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+		}),
+		WithArchive(NewDirStorage(dir), ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(3)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Require().Len(results, 1)
+	suite.EqualValues(3, results[0].Archived)
+	suite.EqualValues(3, results[0].Deleted)
+
+	manifest := suite.readManifest(dir)
+	suite.Equal(ArchiveFormatJSONL, manifest.Format)
+	suite.Require().Len(manifest.Files, 1)
+
+	file := manifest.Files[0]
+	suite.Equal("person_histories", file.Table)
+	suite.EqualValues(3, file.Rows)
+
+	content := suite.readArchiveFile(dir, file)
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		row := make(map[string]interface{})
+		suite.Require().NoError(json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	suite.Require().NoError(scanner.Err())
+	suite.Require().Len(rows, 3)
+	suite.Equal("John", rows[0]["first_name"])
+	suite.Equal(string(ActionCreate), rows[0]["action"])
+
+	suite.assertHistoryCount(p.ID, 1)
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveCSV() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 2,
+		}),
+		WithArchive(NewDirStorage(dir), ArchiveFormatCSV),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	suite.createPersonWithUpdates(2)
+
+	_, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+
+	manifest := suite.readManifest(dir)
+	suite.Require().Len(manifest.Files, 1)
+
+	records, err := csv.NewReader(suite.readArchiveFile(dir, manifest.Files[0])).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2)
+	suite.Contains(records[0], "first_name")
+	suite.Contains(records[1], "John")
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveBatches() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+		}),
+		WithPruneBatchSize(2),
+		WithArchive(NewDirStorage(dir), ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	suite.createPersonWithUpdates(5)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Require().Len(results, 1)
+	suite.EqualValues(5, results[0].Archived)
+	suite.EqualValues(5, results[0].Deleted)
+	suite.Equal(3, results[0].Batches)
+
+	manifest := suite.readManifest(dir)
+	suite.Require().Len(manifest.Files, 3)
+
+	var rows int64
+	for _, file := range manifest.Files {
+		suite.EqualValues(file.Rows, countLines(suite.readArchiveFile(dir, file)))
+		rows += file.Rows
+	}
+	suite.EqualValues(5, rows)
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveFailureKeepsUnarchivedBatches() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+		}),
+		WithPruneBatchSize(2),
+		// the first batch creates its file and the manifest, the file of the second one fails
+		WithArchive(&limitedStorage{ArchiveStorage: NewDirStorage(dir), limit: 2}, ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(5)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().Error(err)
+	suite.Require().Len(results, 1)
+	suite.EqualValues(2, results[0].Archived)
+	suite.EqualValues(2, results[0].Deleted)
+
+	suite.assertHistoryCount(p.ID, 4)
+
+	manifest := suite.readManifest(dir)
+	suite.Require().Len(manifest.Files, 1)
+	suite.EqualValues(2, manifest.Files[0].Rows)
+	suite.EqualValues(2, countLines(suite.readArchiveFile(dir, manifest.Files[0])))
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveRunsInSameSecond() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+		}),
+		WithArchive(NewDirStorage(dir), ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	now := time.Now()
+	suite.db.Config.NowFunc = func() time.Time {
+		return now
+	}
+
+	suite.createPersonWithUpdates(2)
+	_, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+
+	suite.createPersonWithUpdates(2)
+	_, err = Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*", archiveManifestName))
+	suite.Require().NoError(err)
+	suite.Len(matches, 2)
+}
+
+func (suite *PluginTestSuite) TestPruneArchiveFailureKeepsRows() {
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+		}),
+		WithArchive(failingStorage{}, ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(2)
+
+	_, err := Prune(context.Background(), suite.db)
+	suite.Require().Error(err)
+
+	suite.assertHistoryCount(p.ID, 3)
+}
+
+func (suite *PluginTestSuite) readManifest(dir string) ArchiveManifest {
+	matches, err := filepath.Glob(filepath.Join(dir, "*", archiveManifestName))
+	suite.Require().NoError(err)
+	suite.Require().Len(matches, 1)
+
+	b, err := ioutil.ReadFile(matches[0])
+	suite.Require().NoError(err)
+
+	var manifest ArchiveManifest
+	suite.Require().NoError(json.Unmarshal(b, &manifest))
+
+	return manifest
+}
+
+func (suite *PluginTestSuite) readArchiveFile(dir string, file ArchiveFile) io.Reader {
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Name)))
+	suite.Require().NoError(err)
+	suite.EqualValues(len(b), file.Bytes)
+
+	sum := sha256.Sum256(b)
+	suite.Equal(hex.EncodeToString(sum[:]), file.SHA256)
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	suite.Require().NoError(err)
+
+	return r
+}
+
+func countLines(r io.Reader) int {
+	var n int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,348 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	existingJoinsOptionKey = pluginName + ":existing_joins"
+	deletedJoinsOptionKey  = pluginName + ":deleted_joins"
+)
+
+var _ AssociationHistory = (*AssociationEntry)(nil)
+
+type (
+	// AssociationEntry holds the columns of AssociationHistory. Embed it next to Entry to record association changes.
+	AssociationEntry struct {
+		Association    string `gorm:"type:varchar(255)"`
+		AssociationIDs string `gorm:"type:text"`
+	}
+
+	// associationChange holds the ids of the records associated to or dissociated from one owner.
+	associationChange struct {
+		ownerValues []interface{}
+		ids         []interface{}
+	}
+)
+
+// WithAssociationHistory makes the plugin record the changes of the many2many associations of recordable models,
+// e.g. db.Model(&post).Association("Tags").Append(&tag), as ActionAssociate and ActionDissociate entries of the
+// owning record.
+func WithAssociationHistory() ConfigFunc {
+	return func(c *Config) {
+		c.AssociationHistory = true
+	}
+}
+
+func (p *Plugin) beforeAssociate(db *gorm.DB) {
+	rel := p.joinTableRelationship(db)
+	if rel == nil {
+		return
+	}
+
+	rows := joinRows(db.Statement.ReflectValue)
+	if len(rows) == 0 {
+		return
+	}
+
+	fields := rel.JoinTable.PrimaryFields
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.DBName
+	}
+
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = joinRowValues(db, fields, row)
+	}
+
+	column, queryValues := schema.ToQueryValues(rel.JoinTable.Table, columns, values)
+	existing := reflect.New(reflect.SliceOf(rel.JoinTable.ModelType))
+	err := db.
+		Session(&gorm.Session{NewDB: true}).
+		Model(reflect.New(rel.JoinTable.ModelType).Interface()).
+		Where(clause.IN{Column: column, Values: queryValues}).
+		Find(existing.Interface()).
+		Error
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	keys := make(map[string]bool)
+	for _, row := range joinRows(existing.Elem()) {
+		keys[joinRowKey(db, fields, row)] = true
+	}
+
+	db.Statement.Settings.Store(existingJoinsOptionKey, keys)
+}
+
+func (p *Plugin) afterAssociate(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	rel := p.joinTableRelationship(db)
+	if rel == nil {
+		return
+	}
+
+	var existing map[string]bool
+	if value, ok := db.Statement.Settings.Load(existingJoinsOptionKey); ok {
+		existing, _ = value.(map[string]bool)
+	}
+
+	var rows []reflect.Value
+	for _, row := range joinRows(db.Statement.ReflectValue) {
+		if key := joinRowKey(db, rel.JoinTable.PrimaryFields, row); !existing[key] {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := p.recordAssociations(db, rel, ActionAssociate, rows); err != nil {
+		db.AddError(err)
+	}
+}
+
+func (p *Plugin) beforeDissociate(db *gorm.DB) {
+	rel := p.joinTableRelationship(db)
+	if rel == nil {
+		return
+	}
+
+	where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where)
+	if !ok {
+		return
+	}
+
+	deleted := reflect.New(reflect.SliceOf(rel.JoinTable.ModelType))
+	err := db.
+		Session(&gorm.Session{NewDB: true}).
+		Model(reflect.New(rel.JoinTable.ModelType).Interface()).
+		Clauses(where).
+		Find(deleted.Interface()).
+		Error
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	db.Statement.Settings.Store(deletedJoinsOptionKey, deleted.Elem())
+}
+
+func (p *Plugin) afterDissociate(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	rel := p.joinTableRelationship(db)
+	if rel == nil {
+		return
+	}
+
+	value, ok := db.Statement.Settings.Load(deletedJoinsOptionKey)
+	if !ok {
+		return
+	}
+
+	if err := p.recordAssociations(db, rel, ActionDissociate, joinRows(value.(reflect.Value))); err != nil {
+		db.AddError(err)
+	}
+}
+
+// joinTableRelationship returns the many2many relationship of a recordable model whose join table the statement
+// of db writes to, if association history is enabled.
+func (p *Plugin) joinTableRelationship(db *gorm.DB) *schema.Relationship {
+	if !p.associationHistory || db.Statement.Schema == nil || IsDisabled(db) {
+		return nil
+	}
+
+	joinTable := db.Statement.Schema
+	for _, ownerRel := range joinTable.Relationships.Relations {
+		owner := ownerRel.FieldSchema
+		if owner == nil {
+			continue
+		}
+
+		if _, ok := reflect.New(owner.ModelType).Interface().(Recordable); !ok {
+			continue
+		}
+
+		for _, rel := range owner.Relationships.Relations {
+			if rel.Type == schema.Many2Many && rel.JoinTable == joinTable {
+				return rel
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) recordAssociations(db *gorm.DB, rel *schema.Relationship, action Action, rows []reflect.Value) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var ownerRefs, relatedRefs []*schema.Reference
+	for _, ref := range rel.References {
+		if ref.PrimaryValue != "" {
+			continue
+		}
+
+		if ref.OwnPrimaryKey {
+			ownerRefs = append(ownerRefs, ref)
+		} else {
+			relatedRefs = append(relatedRefs, ref)
+		}
+	}
+
+	var (
+		changes []*associationChange
+		owners  = make(map[string]*associationChange)
+	)
+	for _, row := range rows {
+		ownerValues := make([]interface{}, len(ownerRefs))
+		for i, ref := range ownerRefs {
+			ownerValues[i], _ = ref.ForeignKey.ValueOf(db.Statement.Context, row)
+		}
+
+		relatedValues := make([]interface{}, len(relatedRefs))
+		for i, ref := range relatedRefs {
+			relatedValues[i], _ = ref.ForeignKey.ValueOf(db.Statement.Context, row)
+		}
+
+		key := fmt.Sprintf("%v", ownerValues)
+		change, ok := owners[key]
+		if !ok {
+			change = &associationChange{ownerValues: ownerValues}
+			owners[key] = change
+			changes = append(changes, change)
+		}
+
+		if len(relatedValues) == 1 {
+			change.ids = append(change.ids, relatedValues[0])
+		} else {
+			change.ids = append(change.ids, relatedValues)
+		}
+	}
+
+	var hs []History
+	for _, change := range changes {
+		odb := db.Session(&gorm.Session{NewDB: true})
+		owner := reflect.New(rel.Schema.ModelType)
+		tx := odb.Unscoped()
+		for i, ref := range ownerRefs {
+			tx = tx.Where(clause.Eq{
+				Column: clause.Column{Table: rel.Schema.Table, Name: ref.PrimaryKey.DBName},
+				Value:  change.ownerValues[i],
+			})
+		}
+
+		if err := tx.First(owner.Interface()).Error; err != nil {
+			return fmt.Errorf("error loading the owner of association %s: %w", rel.Name, err)
+		}
+
+		if err := odb.Statement.Parse(owner.Interface()); err != nil {
+			return err
+		}
+
+		h, isRecordable, err := p.processStruct(owner.Elem(), action, odb)
+		if err != nil {
+			return err
+		}
+
+		if !isRecordable {
+			continue
+		}
+
+		if ah, ok := historyModel(h).(AssociationHistory); ok {
+			ah.SetHistoryAssociation(rel.Name, change.ids)
+		}
+
+		hs = append(hs, h)
+	}
+
+	return p.saveHistory(db, hs...)
+}
+
+// isAssociationUpdate reports whether the update statement of db only saves associations, like the statements run
+// by the association mode of gorm, so that no update entry is recorded for it.
+func isAssociationUpdate(db *gorm.DB) bool {
+	s := db.Statement.Schema
+	if len(db.Statement.Selects) == 0 {
+		return false
+	}
+
+	var hasRelation bool
+	for _, name := range db.Statement.Selects {
+		name = strings.SplitN(name, ".", 2)[0]
+		if rel, ok := s.Relationships.Relations[name]; ok {
+			if rel.Type != schema.Many2Many {
+				return false
+			}
+
+			hasRelation = true
+
+			continue
+		}
+
+		if s.LookUpField(name) != nil {
+			return false
+		}
+	}
+
+	return hasRelation
+}
+
+func joinRows(v reflect.Value) []reflect.Value {
+	v = reflect.Indirect(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{v}
+	case reflect.Slice, reflect.Array:
+		rows := make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if row := reflect.Indirect(v.Index(i)); row.IsValid() {
+				rows = append(rows, row)
+			}
+		}
+
+		return rows
+	}
+
+	return nil
+}
+
+func joinRowValues(db *gorm.DB, fields []*schema.Field, row reflect.Value) []interface{} {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i], _ = field.ValueOf(db.Statement.Context, row)
+	}
+
+	return values
+}
+
+func joinRowKey(db *gorm.DB, fields []*schema.Field, row reflect.Value) string {
+	return fmt.Sprintf("%v", joinRowValues(db, fields, row))
+}
+
+func (e *AssociationEntry) SetHistoryAssociation(name string, ids []interface{}) {
+	e.Association = name
+
+	b, err := json.Marshal(ids)
+	if err != nil {
+		e.AssociationIDs = fmt.Sprintf("%v", ids)
+
+		return
+	}
+
+	e.AssociationIDs = string(b)
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type (
+	Post struct {
+		ID    uint
+		Title string
+		Tags  []Tag `gorm:"many2many:post_tags"`
+	}
+
+	PostHistory struct {
+		ID uint
+		Entry
+		AssociationEntry
+
+		Title string
+	}
+
+	Tag struct {
+		ID   uint
+		Name string
+	}
+)
+
+func (Post) CreateHistory() History {
+	return &PostHistory{}
+}
+
+func (suite *PluginTestSuite) TestAssociationHistory() {
+	suite.Require().NoError(suite.db.Use(New(WithAssociationHistory())))
+
+	tags := []Tag{{Name: "go"}, {Name: "gorm"}, {Name: "sql"}}
+	suite.Require().NoError(suite.db.Create(&tags).Error)
+
+	post := Post{Title: "Hello"}
+	suite.Require().NoError(suite.db.Create(&post).Error)
+
+	association := func() *gorm.Association {
+		return suite.db.Model(&post).Association("Tags")
+	}
+
+	suite.Require().NoError(association().Append(&tags[0], &tags[1]))
+	suite.Require().NoError(association().Append(&tags[1]))
+	suite.Require().NoError(association().Delete(&tags[0]))
+	suite.Require().NoError(association().Replace(&tags[1], &tags[2]))
+	suite.Require().NoError(association().Clear())
+
+	var entries []PostHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", post.ID).Error)
+
+	actual := make([]string, len(entries))
+	for i, entry := range entries {
+		actual[i] = fmt.Sprintf("%s %s %s", entry.Action, entry.Association, entry.AssociationIDs)
+	}
+
+	suite.Equal([]string{
+		"create  ",
+		fmt.Sprintf("associate Tags [%d,%d]", tags[0].ID, tags[1].ID),
+		fmt.Sprintf("dissociate Tags [%d]", tags[0].ID),
+		fmt.Sprintf("associate Tags [%d]", tags[2].ID),
+		fmt.Sprintf("dissociate Tags [%d,%d]", tags[1].ID, tags[2].ID),
+	}, actual)
+
+	for _, entry := range entries {
+		suite.Equal("Hello", entry.Title)
+	}
+}
+
+func (suite *PluginTestSuite) TestAssociationHistoryOnCreate() {
+	suite.Require().NoError(suite.db.Use(New(WithAssociationHistory())))
+
+	post := Post{
+		Title: "Hello",
+		Tags:  []Tag{{Name: "go"}},
+	}
+	suite.Require().NoError(suite.db.Create(&post).Error)
+
+	var actions []Action
+	err := suite.db.Model(&PostHistory{}).Where("object_id = ?", post.ID).Order("id asc").Pluck("action", &actions).Error
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]Action{ActionCreate, ActionAssociate}, actions)
+}
+
+func (suite *PluginTestSuite) TestAssociationHistoryDisabledByDefault() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	tag := Tag{Name: "go"}
+	suite.Require().NoError(suite.db.Create(&tag).Error)
+
+	post := Post{Title: "Hello"}
+	suite.Require().NoError(suite.db.Create(&post).Error)
+	suite.Require().NoError(suite.db.Model(&post).Association("Tags").Append(&tag))
+
+	var actions []Action
+	err := suite.db.Model(&PostHistory{}).Where("object_id = ?", post.ID).Order("id asc").Pluck("action", &actions).Error
+	suite.Require().NoError(err)
+	suite.NotContains(actions, ActionAssociate)
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+const changeSetOptionKey changeSetOptionCtxKey = pluginName + ":change_set"
+
+var _ ChangeSetHistory = (*ChangeSetEntry)(nil)
+
+type (
+	changeSetOptionCtxKey string
+
+	// ChangeSetEntry holds the column of ChangeSetHistory. Embed it next to Entry to record change set IDs.
+	ChangeSetEntry struct {
+		ChangeSetID string `gorm:"type:varchar(255);index"`
+	}
+
+	ChangeSetMeta struct {
+		Description string
+	}
+
+	// ChangeSet groups the history entries written by one business operation, see BeginChangeSet.
+	ChangeSet struct {
+		ID          string    `gorm:"primaryKey;type:varchar(255)"`
+		Description string    `gorm:"type:text"`
+		CreatedAt   time.Time `gorm:"type:datetime"`
+	}
+)
+
+// WithStatementChangeSets makes the plugin stamp all the history entries written by one statement, including the
+// entries of the associations saved along with the record, with a shared change set ID.
+func WithStatementChangeSets() ConfigFunc {
+	return func(c *Config) {
+		c.StatementChangeSets = true
+	}
+}
+
+func SetChangeSetID(db *gorm.DB, id string) *gorm.DB {
+	ctx := context.WithValue(db.Statement.Context, changeSetOptionKey, id)
+
+	return db.WithContext(ctx).Set(string(changeSetOptionKey), id)
+}
+
+func GetChangeSetID(db *gorm.DB) (string, bool) {
+	value, ok := db.Get(string(changeSetOptionKey))
+	if !ok {
+		value := db.Statement.Context.Value(changeSetOptionKey)
+		id, ok := value.(string)
+
+		return id, ok
+	}
+
+	id, ok := value.(string)
+
+	return id, ok
+}
+
+func NewChangeSetID() string {
+	return ulid.Make().String()
+}
+
+// beginChangeSet runs before the associations of the record are saved, so that the nested statements saving
+// them inherit the change set ID through the statement context.
+func (p *Plugin) beginChangeSet(db *gorm.DB) {
+	if !p.statementChangeSets {
+		return
+	}
+
+	if _, ok := GetChangeSetID(db); ok {
+		return
+	}
+
+	db.Statement.Context = context.WithValue(db.Statement.Context, changeSetOptionKey, NewChangeSetID())
+}
+
+// BeginChangeSet stores a new change set described by meta and returns a db which stamps every history entry written
+// through it with the ID of the change set. Unlike SetChangeSetID, the returned db can be reused for several statements.
+// The ChangeSet model must have been migrated.
+func BeginChangeSet(db *gorm.DB, meta ChangeSetMeta) (*gorm.DB, error) {
+	cs := ChangeSet{
+		ID:          NewChangeSetID(),
+		Description: meta.Description,
+	}
+
+	err := db.
+		Session(&gorm.Session{NewDB: true}).
+		Create(&cs).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("error creating change set: %w", err)
+	}
+
+	return SetChangeSetID(db, cs.ID).Session(&gorm.Session{}), nil
+}
+
+func FindChangeSet(db *gorm.DB, id string) (*ChangeSet, error) {
+	var cs ChangeSet
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&cs, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &cs, nil
+}
+
+// FindChangeSetEntries returns the history entries of models stamped with the change set ID id, ordered by model and
+// then by version.
+func FindChangeSetEntries(db *gorm.DB, id string, models ...Recordable) ([]History, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+
+	var entries []History
+	for _, model := range models {
+		s, err := parseHistorySchema(db, model)
+		if err != nil {
+			return nil, err
+		}
+
+		changeSetCol, err := lookUpColumn(s, "ChangeSetID")
+		if err != nil {
+			return nil, err
+		}
+
+		versionCol, err := lookUpColumn(s, "Version")
+		if err != nil {
+			return nil, err
+		}
+
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(s.ModelType)))
+		err = db.
+			Unscoped().
+			Where(fmt.Sprintf("%s = ?", db.Statement.Quote(changeSetCol)), id).
+			Order(fmt.Sprintf("%s, %s", db.Statement.Quote(versionCol), db.Statement.Quote(s.PrioritizedPrimaryField.DBName))).
+			Find(rows.Interface()).
+			Error
+		if err != nil {
+			return nil, err
+		}
+
+		h := model.CreateHistory()
+		for i := 0; i < rows.Elem().Len(); i++ {
+			entries = append(entries, historyEntry(h, rows.Elem().Index(i).Interface()))
+		}
+	}
+
+	return entries, nil
+}
+
+func (e *ChangeSetEntry) SetHistoryChangeSetID(id string) {
+	e.ChangeSetID = id
+}
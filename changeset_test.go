@@ -0,0 +1,88 @@
+package history
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+func (suite *PluginTestSuite) TestStatementChangeSets() {
+	suite.Require().NoError(suite.db.Use(New(WithStatementChangeSets())))
+
+	p := Person{
+		FirstName: "John",
+		Address:   &Address{Line1: "Line 1"},
+	}
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	p.FirstName = "Jane"
+	p.Address.Line1 = "Line 2"
+	suite.Require().NoError(suite.db.Session(&gorm.Session{FullSaveAssociations: true}).Save(&p).Error)
+
+	var people []PersonHistory
+	suite.Require().NoError(suite.db.Order("version asc").Find(&people, "object_id = ?", p.ID).Error)
+	suite.Require().Len(people, 2)
+
+	var addresses []AddressHistory
+	suite.Require().NoError(suite.db.Order("version asc").Find(&addresses, "object_id = ?", p.Address.ID).Error)
+	suite.Require().Len(addresses, 2)
+
+	for i := range people {
+		suite.NotZero(people[i].ChangeSetID)
+		suite.Equal(people[i].ChangeSetID, addresses[i].ChangeSetID)
+	}
+
+	suite.NotEqual(people[0].ChangeSetID, people[1].ChangeSetID)
+}
+
+func (suite *PluginTestSuite) TestSetChangeSetID() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	db := SetChangeSetID(suite.db, "my-change-set")
+	p.FirstName = "Jane"
+	suite.Require().NoError(db.Save(&p).Error)
+
+	var ids []string
+	err := suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Order("version asc").Pluck("change_set_id", &ids).Error
+	suite.Require().NoError(err)
+	suite.Equal([]string{"", "my-change-set"}, ids)
+}
+
+func (suite *PluginTestSuite) TestBeginChangeSet() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	db, err := BeginChangeSet(suite.db, ChangeSetMeta{Description: "move out"})
+	suite.Require().NoError(err)
+
+	id, ok := GetChangeSetID(db)
+	suite.Require().True(ok)
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(db.Create(&p).Error)
+
+	a := Address{Line1: "Line 1"}
+	suite.Require().NoError(db.Create(&a).Error)
+
+	other := Person{FirstName: "Other"}
+	suite.Require().NoError(suite.db.Create(&other).Error)
+
+	cs, err := FindChangeSet(suite.db, id)
+	suite.Require().NoError(err)
+	suite.Equal("move out", cs.Description)
+	suite.NotZero(cs.CreatedAt)
+
+	entries, err := FindChangeSetEntries(suite.db, id, &Person{}, &Address{})
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+
+	ph, ok := entries[0].(*PersonHistory)
+	suite.Require().True(ok)
+	suite.Equal(fmt.Sprintf("%d", p.ID), ph.ObjectID)
+
+	ah, ok := entries[1].(*AddressHistory)
+	suite.Require().True(ok)
+	suite.Equal(fmt.Sprintf("%d", a.ID), ah.ObjectID)
+}
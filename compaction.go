@@ -0,0 +1,227 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type (
+	// CompactionPolicy describes how the history entries of Model older than OlderThan are compacted:
+	// consecutive ActionUpdate entries of an object made by the same user and source, each within Window of
+	// the previous one, are collapsed into the last one. Create entries and entries of any other action are
+	// kept.
+	CompactionPolicy struct {
+		Model     Recordable
+		OlderThan time.Duration
+		Window    time.Duration
+	}
+
+	compactionRow struct {
+		id         interface{}
+		objectID   interface{}
+		version    string
+		action     Action
+		userID     sql.NullString
+		sourceID   sql.NullString
+		sourceType sql.NullString
+		createdAt  time.Time
+	}
+
+	// compactionCursor is the position of findCompactable in the entries of a table, ordered by object, version and
+	// primary key: the last entry read and the last update of the burst it is part of, if any.
+	compactionCursor struct {
+		last *compactionRow
+		run  *compactionRow
+	}
+)
+
+func WithCompactionPolicy(policies ...CompactionPolicy) ConfigFunc {
+	return func(c *Config) {
+		c.CompactionPolicies = append(c.CompactionPolicies, policies...)
+	}
+}
+
+// Compact collapses bursts of updates according to the compaction policies the plugin registered on db was
+// configured with. The deleted entries are archived first when the plugin is configured WithArchive.
+func Compact(ctx context.Context, db *gorm.DB) ([]PruneResult, error) {
+	p, err := getPlugin(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Compact(ctx, db)
+}
+
+func (p *Plugin) Compact(ctx context.Context, db *gorm.DB) (results []PruneResult, err error) {
+	db = db.Session(&gorm.Session{
+		NewDB:   true,
+		Context: ctx,
+	})
+
+	now := db.NowFunc()
+	var archive *archiveRun
+	if p.archiveStorage != nil {
+		if archive, err = p.newArchiveRun(now); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			if cerr := archive.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("error archiving history: %w", cerr)
+			}
+		}()
+	}
+
+	results = make([]PruneResult, 0, len(p.compactionPolicies))
+	for _, policy := range p.compactionPolicies {
+		s, err := parseHistorySchema(db, policy.Model)
+		if err != nil {
+			return results, err
+		}
+
+		result := PruneResult{Table: s.Table}
+		if policy.Window > 0 {
+			err = p.compact(db, archive, s, policy, now, &result)
+		}
+
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// compact archives and deletes the collapsed entries of s, reading at most PruneBatchSize entries at a time.
+func (p *Plugin) compact(db *gorm.DB, archive *archiveRun, s *schema.Schema, policy CompactionPolicy, now time.Time, result *PruneResult) error {
+	var cursor compactionCursor
+	for {
+		ids, n, err := p.findCompactable(db, s, policy, now, &cursor)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			archived, deleted, err := p.deleteBatch(db, archive, s, ids)
+			result.Archived += archived
+			result.Deleted += deleted
+
+			if err != nil {
+				return err
+			}
+
+			result.Batches++
+		}
+
+		if n < p.pruneBatchSize {
+			return nil
+		}
+	}
+}
+
+// findCompactable reads the next PruneBatchSize entries of s after cursor and returns the primary keys of those
+// collapsed into a later entry, along with the number of entries read.
+func (p *Plugin) findCompactable(db *gorm.DB, s *schema.Schema, policy CompactionPolicy, now time.Time, cursor *compactionCursor) ([]interface{}, int, error) {
+	columns := make(map[string]string)
+	for _, name := range []string{"ObjectID", "Version", "Action", "CreatedAt"} {
+		col, err := lookUpColumn(s, name)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		columns[name] = db.Statement.Quote(col)
+	}
+
+	for _, name := range []string{"UserID", "SourceID", "SourceType"} {
+		columns[name] = "NULL"
+		if col, err := lookUpColumn(s, name); err == nil {
+			columns[name] = db.Statement.Quote(col)
+		}
+	}
+
+	pk := db.Statement.Quote(s.PrioritizedPrimaryField.DBName)
+	q := db.
+		Unscoped().
+		Model(reflect.New(s.ModelType).Interface()).
+		Select(fmt.Sprintf(
+			"%s, %s, %s, %s, %s, %s, %s, %s",
+			pk, columns["ObjectID"], columns["Version"], columns["Action"], columns["UserID"], columns["SourceID"],
+			columns["SourceType"], columns["CreatedAt"],
+		)).
+		Where(fmt.Sprintf("%s < ?", columns["CreatedAt"]), now.Add(-policy.OlderThan)).
+		Order(fmt.Sprintf("%s, %s, %s", columns["ObjectID"], columns["Version"], pk)).
+		Limit(p.pruneBatchSize)
+
+	if last := cursor.last; last != nil {
+		q = q.Where(
+			fmt.Sprintf(
+				"(%[1]s > @object OR (%[1]s = @object AND (%[2]s > @version OR (%[2]s = @version AND %[3]s > @id))))",
+				columns["ObjectID"], columns["Version"], pk,
+			),
+			sql.Named("object", last.objectID),
+			sql.Named("version", last.version),
+			sql.Named("id", last.id),
+		)
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		ids []interface{}
+		n   int
+	)
+
+	for rows.Next() {
+		var row compactionRow
+		err := rows.Scan(
+			&row.id, &row.objectID, &row.version, &row.action, &row.userID, &row.sourceID, &row.sourceType,
+			&row.createdAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if b, ok := row.objectID.([]byte); ok {
+			row.objectID = string(b)
+		}
+
+		if cursor.run != nil && cursor.run.mergeable(row, policy.Window) {
+			ids = append(ids, cursor.run.id)
+		}
+
+		cursor.run = nil
+		if row.action == ActionUpdate {
+			cursor.run = &row
+		}
+
+		cursor.last = &row
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return ids, n, nil
+}
+
+// mergeable tells whether other, the entry following r, is part of the same burst of updates as r.
+func (r compactionRow) mergeable(other compactionRow, window time.Duration) bool {
+	return fmt.Sprintf("%v", r.objectID) == fmt.Sprintf("%v", other.objectID) &&
+		r.action == other.action &&
+		r.userID == other.userID &&
+		r.sourceID == other.sourceID &&
+		r.sourceType == other.sourceType &&
+		other.createdAt.Sub(r.createdAt) <= window
+}
@@ -0,0 +1,256 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (suite *PluginTestSuite) TestCompactSameUser() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	john := SetUser(suite.db, User{ID: "john"})
+	jane := SetUser(suite.db, User{ID: "jane"})
+
+	p := Person{FirstName: "Created"}
+	suite.Require().NoError(john.Create(&p).Error)
+
+	for _, update := range []struct {
+		db   string
+		name string
+	}{
+		{"john", "John 1"},
+		{"john", "John 2"},
+		{"john", "John 3"},
+		{"jane", "Jane 1"},
+		{"john", "John 4"},
+		{"john", "John 5"},
+	} {
+		db := john
+		if update.db == "jane" {
+			db = jane
+		}
+
+		p.FirstName = update.name
+		suite.Require().NoError(db.Save(&p).Error)
+	}
+
+	err := suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", p.ID).
+		Update("created_at", suite.db.NowFunc().Add(-2*time.Hour)).
+		Error
+	suite.Require().NoError(err)
+
+	results, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Require().Len(results, 1)
+	suite.EqualValues(3, results[0].Deleted)
+
+	var names []string
+	err = suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", p.ID).
+		Order("version asc").
+		Pluck("first_name", &names).
+		Error
+	suite.Require().NoError(err)
+	suite.Equal([]string{"Created", "John 3", "Jane 1", "John 5"}, names)
+}
+
+func (suite *PluginTestSuite) TestCompactWindow() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(4)
+
+	var entries []PersonHistory
+	err := suite.db.Order("version asc").Find(&entries, "object_id = ?", p.ID).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 5)
+
+	start := suite.db.NowFunc().Add(-24 * time.Hour)
+	offsets := []time.Duration{0, time.Second, 30 * time.Second, 5 * time.Minute, 10 * time.Minute}
+	for i, entry := range entries {
+		err := suite.db.
+			Model(&PersonHistory{}).
+			Where("id = ?", entry.ID).
+			Update("created_at", start.Add(offsets[i])).
+			Error
+		suite.Require().NoError(err)
+	}
+
+	_, err = Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+
+	var names []string
+	err = suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", p.ID).
+		Order("version asc").
+		Pluck("first_name", &names).
+		Error
+	suite.Require().NoError(err)
+	suite.Equal([]string{"John", "Jane 2", "Jane 3", "Jane 4"}, names)
+}
+
+func (suite *PluginTestSuite) TestCompactKeepsRecentEntries() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(3)
+
+	results, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Zero(results[0].Deleted)
+
+	suite.assertHistoryCount(p.ID, 4)
+}
+
+func (suite *PluginTestSuite) TestCompactSteadyBurst() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(4)
+	suite.setHistoryCreatedAt(p.ID, 0, 40*time.Second, 80*time.Second, 120*time.Second, 160*time.Second)
+
+	results, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.EqualValues(3, results[0].Deleted)
+
+	suite.Equal([]string{"John", "Jane 4"}, suite.historyFirstNames(p.ID))
+}
+
+func (suite *PluginTestSuite) TestCompactBatches() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+		WithPruneBatchSize(2),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p1 := suite.createPersonWithUpdates(4)
+	suite.setHistoryCreatedAt(p1.ID, 0, time.Second, 2*time.Second, 3*time.Second, 4*time.Second)
+	p2 := suite.createPersonWithUpdates(2)
+	suite.setHistoryCreatedAt(p2.ID, 0, time.Second, 2*time.Second)
+
+	results, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.EqualValues(4, results[0].Deleted)
+	suite.Greater(results[0].Batches, 1)
+
+	suite.Equal([]string{"John", "Jane 4"}, suite.historyFirstNames(p1.ID))
+	suite.Equal([]string{"John", "Jane 2"}, suite.historyFirstNames(p2.ID))
+}
+
+func (suite *PluginTestSuite) TestCompactSourceType() {
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	for i, typ := range []string{"http", "http", "grpc"} {
+		p.FirstName = fmt.Sprintf("Jane %d", i+1)
+		db := SetSource(suite.db, Source{ID: "1", Type: typ})
+		suite.Require().NoError(db.Save(&p).Error)
+	}
+
+	suite.setHistoryCreatedAt(p.ID, 0, time.Second, 2*time.Second, 3*time.Second)
+
+	_, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{"John", "Jane 2", "Jane 3"}, suite.historyFirstNames(p.ID))
+}
+
+func (suite *PluginTestSuite) TestCompactArchive() {
+	dir := suite.T().TempDir()
+	plugin := New(
+		WithCompactionPolicy(CompactionPolicy{
+			Model:     &Person{},
+			OlderThan: time.Hour,
+			Window:    time.Minute,
+		}),
+		WithArchive(NewDirStorage(dir), ArchiveFormatJSONL),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(3)
+	suite.setHistoryCreatedAt(p.ID, 0, time.Second, 2*time.Second, 3*time.Second)
+
+	results, err := Compact(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.EqualValues(2, results[0].Archived)
+	suite.EqualValues(2, results[0].Deleted)
+
+	manifest := suite.readManifest(dir)
+	suite.Require().Len(manifest.Files, 1)
+	suite.EqualValues(2, manifest.Files[0].Rows)
+}
+
+// setHistoryCreatedAt sets the creation time of the history entries of the object, in version order, to a day ago
+// plus offsets.
+func (suite *PluginTestSuite) setHistoryCreatedAt(objectID uint, offsets ...time.Duration) {
+	var entries []PersonHistory
+	err := suite.db.Order("version asc").Find(&entries, "object_id = ?", objectID).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, len(offsets))
+
+	start := suite.db.NowFunc().Add(-24 * time.Hour)
+	for i, entry := range entries {
+		err := suite.db.
+			Model(&PersonHistory{}).
+			Where("id = ?", entry.ID).
+			Update("created_at", start.Add(offsets[i])).
+			Error
+		suite.Require().NoError(err)
+	}
+}
+
+func (suite *PluginTestSuite) historyFirstNames(objectID uint) []string {
+	var names []string
+	err := suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", objectID).
+		Order("version asc").
+		Pluck("first_name", &names).
+		Error
+	suite.Require().NoError(err)
+
+	return names
+}
@@ -0,0 +1,177 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const expectedVersionOptionKey = pluginName + ":expected_version"
+
+var ErrVersionConflict = errors.New("history version conflict")
+
+type VersionConflictError struct {
+	ObjectID interface{}
+	Expected Version
+	Actual   Version
+}
+
+// ExpectVersion makes the updates run through the returned db fail with a VersionConflictError when the latest
+// history version of the updated object is not v. The object row is locked (SELECT ... FOR UPDATE) before its
+// latest version is read, so the check holds until the transaction of the update ends; with SkipDefaultTransaction,
+// run the update in a transaction. Creates are not checked. Unlike SetUser, the expectation is not propagated
+// through the context, so the associations saved along with the object are not checked.
+func ExpectVersion(db *gorm.DB, v Version) *gorm.DB {
+	return db.Set(expectedVersionOptionKey, v)
+}
+
+func GetExpectedVersion(db *gorm.DB) (Version, bool) {
+	value, ok := db.Get(expectedVersionOptionKey)
+	if !ok {
+		return "", false
+	}
+
+	v, ok := value.(Version)
+
+	return v, ok
+}
+
+// LatestVersion returns the greatest history version recorded for the object r identified by objectID.
+func LatestVersion(db *gorm.DB, r Recordable, objectID interface{}) (Version, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(historyModel(r.CreateHistory())); err != nil {
+		return "", err
+	}
+
+	objectIDCol, err := lookUpColumn(stmt.Schema, "ObjectID")
+	if err != nil {
+		return "", err
+	}
+
+	versionCol, err := lookUpColumn(stmt.Schema, "Version")
+	if err != nil {
+		return "", err
+	}
+
+	var latest sql.NullString
+	err = db.
+		Unscoped().
+		Table(stmt.Schema.Table).
+		Select(fmt.Sprintf("MAX(%s)", db.Statement.Quote(versionCol))).
+		Where(fmt.Sprintf("%s = ?", db.Statement.Quote(objectIDCol)), objectIDValue(stmt.Schema, objectID)).
+		Row().
+		Scan(&latest)
+	if err != nil {
+		return "", err
+	}
+
+	return Version(latest.String), nil
+}
+
+func (p *Plugin) checkVersion(db *gorm.DB) {
+	if err := checkExpectedVersion(db); err != nil {
+		db.AddError(err)
+	}
+}
+
+// checkExpectedVersion returns a VersionConflictError when the latest history version of one of the objects of the
+// statement of db is not the version expected through ExpectVersion.
+func checkExpectedVersion(db *gorm.DB) error {
+	if db.Statement.Schema == nil {
+		return nil
+	}
+
+	expected, ok := GetExpectedVersion(db)
+	if !ok {
+		return nil
+	}
+
+	v := db.Statement.ReflectValue
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return checkObjectVersion(db, v, expected)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if isNil(v.Index(i)) {
+				continue
+			}
+
+			if err := checkObjectVersion(db, v.Index(i), expected); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkObjectVersion(db *gorm.DB, v reflect.Value, expected Version) error {
+	r, ok := v.Interface().(Recordable)
+	if !ok {
+		return nil
+	}
+
+	pk, err := getPrimaryKeyValue(db, v)
+	if err != nil {
+		return err
+	}
+
+	if pk.isZero {
+		return fmt.Errorf("not able to determine record primary key value: %w", ErrUnsupportedOperation)
+	}
+
+	if err := lockObject(db, v, pk); err != nil {
+		return err
+	}
+
+	actual, err := LatestVersion(db, r, pk.value)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return &VersionConflictError{
+			ObjectID: pk.value,
+			Expected: expected,
+			Actual:   actual,
+		}
+	}
+
+	return nil
+}
+
+// lockObject locks the row of the object v until the transaction of db ends, so that the concurrent updates of the
+// object wait for it to be recorded before checking their expected version. Databases without row locks, like
+// SQLite, ignore the locking clause and serialize the write transactions instead.
+func lockObject(db *gorm.DB, v reflect.Value, pk *primaryKeyField) error {
+	s := db.Statement.Schema
+	tx := db.
+		Session(&gorm.Session{NewDB: true}).
+		Unscoped().
+		Model(reflect.New(s.ModelType).Interface()).
+		Clauses(clause.Locking{Strength: "UPDATE"})
+
+	v = reflect.Indirect(v)
+	for _, name := range pk.names {
+		field := s.LookUpField(name)
+		tx = tx.Where(fmt.Sprintf("%s = ?", db.Statement.Quote(field.DBName)), v.FieldByName(name).Interface())
+	}
+
+	var ids []interface{}
+
+	return tx.Pluck(s.LookUpField(pk.names[0]).DBName, &ids).Error
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: expected version %q of object %v but the latest is %q", ErrVersionConflict, e.Expected, e.ObjectID, e.Actual)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
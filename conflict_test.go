@@ -0,0 +1,108 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func (suite *PluginTestSuite) TestExpectVersion() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	latest, err := LatestVersion(suite.db, p, p.ID)
+	suite.Require().NoError(err)
+	suite.Zero(latest)
+
+	p.FirstName = "Jane"
+	err = ExpectVersion(suite.db, latest).Save(&p).Error
+	suite.Require().NoError(err)
+
+	current, err := LatestVersion(suite.db, p, p.ID)
+	suite.Require().NoError(err)
+	suite.NotZero(current)
+
+	p.FirstName = "Stale"
+	err = ExpectVersion(suite.db, latest).Model(&p).Update("first_name", "Stale").Error
+	suite.Require().Error(err)
+	suite.True(errors.Is(err, ErrVersionConflict))
+
+	var conflict *VersionConflictError
+	suite.Require().True(errors.As(err, &conflict))
+	suite.Equal(latest, conflict.Expected)
+	suite.Equal(current, conflict.Actual)
+
+	var stored Person
+	suite.Require().NoError(suite.db.First(&stored, p.ID).Error)
+	suite.Equal("Jane", stored.FirstName)
+	suite.assertHistoryCount(p.ID, 2)
+
+	err = ExpectVersion(suite.db, current).Model(&p).Update("first_name", "Fresh").Error
+	suite.Require().NoError(err)
+	suite.assertHistoryCount(p.ID, 3)
+}
+
+func (suite *PluginTestSuite) TestExpectVersionIsNotPropagatedToAssociations() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{
+		FirstName: "John",
+		Address:   &Address{Line1: "Line 1"},
+	}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	p.Address.Line1 = "Line 2"
+	err := ExpectVersion(suite.db, "").Save(&p).Error
+	suite.Require().NoError(err)
+}
+
+func TestExpectVersionConcurrentUpdates(t *testing.T) {
+	db := openFileDB(t, &gorm.Config{}, New())
+
+	p := Person{FirstName: "John"}
+	require.NoError(t, db.Create(&p).Error)
+
+	latest, err := LatestVersion(db, p, p.ID)
+	require.NoError(t, err)
+
+	n := 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			np := p
+			errs <- ExpectVersion(db, latest).Model(&np).Update("first_name", fmt.Sprintf("Jane %d", i)).Error
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var updated int
+	for err := range errs {
+		if err == nil {
+			updated++
+
+			continue
+		}
+
+		require.True(t, errors.Is(err, ErrVersionConflict), err)
+	}
+
+	require.Equal(t, 1, updated)
+
+	var count int64
+	require.NoError(t, db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Count(&count).Error)
+	require.EqualValues(t, 2, count)
+}
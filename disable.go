@@ -0,0 +1,86 @@
+package history
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+const (
+	disabledModelsOptionKey  disabledOptionCtxKey = pluginName + ":disabled_models"
+	disabledActionsOptionKey disabledOptionCtxKey = pluginName + ":disabled_actions"
+)
+
+// DisableFor stops the history of models from being recorded by the statements run through the returned db, while
+// the history of the other models is still recorded.
+func DisableFor(db *gorm.DB, models ...Recordable) *gorm.DB {
+	disabled := make(map[reflect.Type]bool)
+	for t := range disabledModels(db) {
+		disabled[t] = true
+	}
+
+	for _, model := range models {
+		if t, err := modelType(model); err == nil {
+			disabled[t] = true
+		}
+	}
+
+	ctx := context.WithValue(db.Statement.Context, disabledModelsOptionKey, disabled)
+
+	return db.WithContext(ctx).Set(string(disabledModelsOptionKey), disabled)
+}
+
+// DisableActions stops the history entries of actions from being recorded by the statements run through the
+// returned db.
+func DisableActions(db *gorm.DB, actions ...Action) *gorm.DB {
+	disabled := make(map[Action]bool)
+	for action := range disabledActions(db) {
+		disabled[action] = true
+	}
+
+	for _, action := range actions {
+		disabled[action] = true
+	}
+
+	ctx := context.WithValue(db.Statement.Context, disabledActionsOptionKey, disabled)
+
+	return db.WithContext(ctx).Set(string(disabledActionsOptionKey), disabled)
+}
+
+// IsDisabledFor reports whether the history entry of model for action is not recorded by the statements run
+// through db, because of Disable, DisableFor or DisableActions.
+func IsDisabledFor(db *gorm.DB, model Recordable, action Action) bool {
+	if IsDisabled(db) || disabledActions(db)[action] {
+		return true
+	}
+
+	t, err := modelType(model)
+	if err != nil {
+		return false
+	}
+
+	return disabledModels(db)[t]
+}
+
+func disabledModels(db *gorm.DB) map[reflect.Type]bool {
+	value, ok := db.Get(string(disabledModelsOptionKey))
+	if !ok {
+		value = db.Statement.Context.Value(disabledModelsOptionKey)
+	}
+
+	models, _ := value.(map[reflect.Type]bool)
+
+	return models
+}
+
+func disabledActions(db *gorm.DB) map[Action]bool {
+	value, ok := db.Get(string(disabledActionsOptionKey))
+	if !ok {
+		value = db.Statement.Context.Value(disabledActionsOptionKey)
+	}
+
+	actions, _ := value.(map[Action]bool)
+
+	return actions
+}
@@ -0,0 +1,55 @@
+package history
+
+import "gorm.io/gorm"
+
+func (suite *PluginTestSuite) TestDisableFor() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	a := Address{Line1: "Line 1"}
+	err := suite.db.Transaction(func(tx *gorm.DB) error {
+		if err := DisableFor(tx, &Person{}).Create(&p).Error; err != nil {
+			return err
+		}
+
+		return DisableFor(tx, &Person{}).Create(&a).Error
+	})
+	suite.Require().NoError(err)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Count(&count).Error)
+	suite.Zero(count)
+
+	suite.Require().NoError(suite.db.Model(&AddressHistory{}).Where("object_id = ?", a.ID).Count(&count).Error)
+	suite.EqualValues(1, count)
+}
+
+func (suite *PluginTestSuite) TestDisableActions() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	db := DisableActions(suite.db, ActionUpdate)
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(DisableActions(suite.db, ActionUpdate).Save(&p).Error)
+
+	var actions []Action
+	err := suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Pluck("action", &actions).Error
+	suite.Require().NoError(err)
+	suite.Equal([]Action{ActionCreate}, actions)
+}
+
+func (suite *PluginTestSuite) TestDisabledBulkUpdate() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	for _, db := range []*gorm.DB{DisableFor(suite.db, &Person{}), DisableActions(suite.db, ActionUpdate)} {
+		err := db.Model(&Person{}).Where("first_name = ?", "John").Update("last_name", "Doe").Error
+		suite.Require().NoError(err)
+	}
+
+	suite.assertHistoryCount(p.ID, 1)
+}
@@ -8,10 +8,16 @@ import (
 )
 
 const (
-	ActionCreate    Action             = "create"
-	ActionUpdate    Action             = "update"
-	userOptionKey   userOptionCtxKey   = pluginName + ":user"
-	sourceOptionKey sourceOptionCtxKey = pluginName + ":source"
+	ActionCreate     Action             = "create"
+	ActionUpdate     Action             = "update"
+	ActionAssociate  Action             = "associate"
+	ActionDissociate Action             = "dissociate"
+	userOptionKey    userOptionCtxKey   = pluginName + ":user"
+	sourceOptionKey  sourceOptionCtxKey = pluginName + ":source"
+
+	ActorHuman   ActorType = "human"
+	ActorService ActorType = "service"
+	ActorSystem  ActorType = "system"
 )
 
 var (
@@ -19,6 +25,10 @@ var (
 	_ TimestampableHistory = (*Entry)(nil)
 	_ BlameableHistory     = (*Entry)(nil)
 	_ SourceableHistory    = (*Entry)(nil)
+	_ TimestampableHistory = (*TypedEntry)(nil)
+	_ BlameableHistory     = (*TypedEntry)(nil)
+	_ SourceableHistory    = (*TypedEntry)(nil)
+	_ ActorHistory         = (*ActorEntry)(nil)
 )
 
 type (
@@ -26,6 +36,8 @@ type (
 
 	Action string
 
+	ActorType string
+
 	userOptionCtxKey string
 
 	sourceOptionCtxKey string
@@ -43,11 +55,37 @@ type (
 		SetHistoryUserEmail(email string)
 	}
 
+	// ActorHistory records who the user of a change is beyond its ID and email: the name to display, whether it
+	// is a human, a service account or the system itself, and the user it acted on behalf of, if any.
+	ActorHistory interface {
+		SetHistoryUserName(name string)
+		SetHistoryActorType(typ ActorType)
+		SetHistoryOnBehalfOf(user User)
+	}
+
 	SourceableHistory interface {
 		SetHistorySourceID(ID string)
 		SetHistorySourceType(typ string)
 	}
 
+	ReasonableHistory interface {
+		SetHistoryReason(reason string)
+	}
+
+	ChangeSetHistory interface {
+		SetHistoryChangeSetID(id string)
+	}
+
+	AssociationHistory interface {
+		SetHistoryAssociation(name string, ids []interface{})
+	}
+
+	RawActionHistory interface {
+		SetHistoryRawAction(action Action)
+	}
+
+	// History is a history model, like the models embedding Entry. Wrap the models which don't implement it in
+	// a TaggedHistory.
 	History interface {
 		SetHistoryVersion(version Version)
 		SetHistoryObjectID(id interface{})
@@ -65,9 +103,47 @@ type (
 		CreatedAt  time.Time `gorm:"type:datetime"`
 	}
 
+	// TypedEntry is an Entry without the object ID, which the history models embedding it declare with the type
+	// of the primary key of the object and wrap in a TaggedHistory, e.g.
+	//
+	//	type PersonHistory struct {
+	//		gorm.Model
+	//		history.TypedEntry
+	//
+	//		ObjectID uint `gorm:"index" gorm-history:"objectID"`
+	//	}
+	//
+	//	func (Person) CreateHistory() history.History {
+	//		return history.NewTaggedHistory(&PersonHistory{})
+	//	}
+	//
+	// so that the history can be queried and joined without casting the object IDs.
+	TypedEntry struct {
+		Version    Version   `gorm:"type:char(26)"`
+		Action     Action    `gorm:"type:varchar(24)"`
+		UserID     string    `gorm:"type:varchar(255)"`
+		UserEmail  string    `gorm:"type:varchar(255)"`
+		SourceID   string    `gorm:"type:varchar(255)"`
+		SourceType string    `gorm:"type:varchar(255)"`
+		CreatedAt  time.Time `gorm:"type:datetime"`
+	}
+
+	// ActorEntry holds the columns of ActorHistory. Embed it next to Entry to record them.
+	ActorEntry struct {
+		UserName        string    `gorm:"type:varchar(255)"`
+		ActorType       ActorType `gorm:"type:varchar(24)"`
+		OnBehalfOfID    string    `gorm:"type:varchar(255)"`
+		OnBehalfOfEmail string    `gorm:"type:varchar(255)"`
+	}
+
 	User struct {
 		ID    string
 		Email string
+		Name  string
+		Type  ActorType
+		// OnBehalfOf is the user on whose behalf the change is made, e.g. the customer an admin impersonates or
+		// the user a job runs for.
+		OnBehalfOf *User
 	}
 
 	Source struct {
@@ -77,7 +153,7 @@ type (
 )
 
 func SetUser(db *gorm.DB, user User) *gorm.DB {
-	ctx := context.WithValue(db.Statement.Context, userOptionKey, user)
+	ctx := ContextWithUser(db.Statement.Context, user)
 
 	return db.WithContext(ctx).Set(string(userOptionKey), user)
 }
@@ -85,10 +161,7 @@ func SetUser(db *gorm.DB, user User) *gorm.DB {
 func GetUser(db *gorm.DB) (User, bool) {
 	value, ok := db.Get(string(userOptionKey))
 	if !ok {
-		value := db.Statement.Context.Value(userOptionKey)
-		user, ok := value.(User)
-
-		return user, ok
+		return UserFromContext(db.Statement.Context)
 	}
 
 	user, ok := value.(User)
@@ -96,8 +169,20 @@ func GetUser(db *gorm.DB) (User, bool) {
 	return user, ok
 }
 
+// ContextWithUser returns a copy of ctx carrying user, which is recorded by the statements run with
+// db.WithContext(ctx), the same as with SetUser.
+func ContextWithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userOptionKey, user)
+}
+
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userOptionKey).(User)
+
+	return user, ok
+}
+
 func SetSource(db *gorm.DB, source Source) *gorm.DB {
-	ctx := context.WithValue(db.Statement.Context, sourceOptionKey, source)
+	ctx := ContextWithSource(db.Statement.Context, source)
 
 	return db.WithContext(ctx).Set(string(sourceOptionKey), source)
 }
@@ -105,10 +190,7 @@ func SetSource(db *gorm.DB, source Source) *gorm.DB {
 func GetSource(db *gorm.DB) (Source, bool) {
 	value, ok := db.Get(string(sourceOptionKey))
 	if !ok {
-		value := db.Statement.Context.Value(sourceOptionKey)
-		source, ok := value.(Source)
-
-		return source, ok
+		return SourceFromContext(db.Statement.Context)
 	}
 
 	source, ok := value.(Source)
@@ -116,6 +198,18 @@ func GetSource(db *gorm.DB) (Source, bool) {
 	return source, ok
 }
 
+// ContextWithSource returns a copy of ctx carrying source, which is recorded by the statements run with
+// db.WithContext(ctx), the same as with SetSource.
+func ContextWithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceOptionKey, source)
+}
+
+func SourceFromContext(ctx context.Context) (Source, bool) {
+	source, ok := ctx.Value(sourceOptionKey).(Source)
+
+	return source, ok
+}
+
 func (e *Entry) SetHistoryVersion(version Version) {
 	e.Version = version
 }
@@ -147,3 +241,44 @@ func (e *Entry) SetHistorySourceID(id string) {
 func (e *Entry) SetHistorySourceType(typ string) {
 	e.SourceType = typ
 }
+
+func (e *TypedEntry) SetHistoryVersion(version Version) {
+	e.Version = version
+}
+
+func (e *TypedEntry) SetHistoryAction(action Action) {
+	e.Action = action
+}
+
+func (e *TypedEntry) SetHistoryUserID(id string) {
+	e.UserID = id
+}
+
+func (e *TypedEntry) SetHistoryUserEmail(email string) {
+	e.UserEmail = email
+}
+
+func (e *TypedEntry) SetHistoryCreatedAt(createdAt time.Time) {
+	e.CreatedAt = createdAt
+}
+
+func (e *TypedEntry) SetHistorySourceID(id string) {
+	e.SourceID = id
+}
+
+func (e *TypedEntry) SetHistorySourceType(typ string) {
+	e.SourceType = typ
+}
+
+func (e *ActorEntry) SetHistoryUserName(name string) {
+	e.UserName = name
+}
+
+func (e *ActorEntry) SetHistoryActorType(typ ActorType) {
+	e.ActorType = typ
+}
+
+func (e *ActorEntry) SetHistoryOnBehalfOf(user User) {
+	e.OnBehalfOfID = user.ID
+	e.OnBehalfOfEmail = user.Email
+}
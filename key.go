@@ -0,0 +1,20 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CompositeKey is the object ID of the models having a composite primary key: the values of the primary key
+// fields in the order they are declared. It is stored as its canonical string form, the JSON array of the values,
+// e.g. [42,1] for OrderLine{OrderID: 42, LineNo: 1}.
+type CompositeKey []interface{}
+
+func (k CompositeKey) String() string {
+	b, err := json.Marshal([]interface{}(k))
+	if err != nil {
+		return fmt.Sprintf("%v", []interface{}(k))
+	}
+
+	return string(b)
+}
@@ -0,0 +1,119 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	OrderLine struct {
+		OrderID uint `gorm:"primaryKey;autoIncrement:false"`
+		LineNo  uint `gorm:"primaryKey;autoIncrement:false"`
+		Product string
+	}
+
+	OrderLineHistory struct {
+		ID uint `gorm:"primaryKey"`
+		Entry
+
+		OrderID uint
+		LineNo  uint
+		Product string
+	}
+
+	TenantDocument struct {
+		ID       uint `gorm:"primaryKey;autoIncrement:false"`
+		TenantID uint `gorm:"primaryKey;autoIncrement:false"`
+		Title    string
+	}
+
+	TenantDocumentHistory struct {
+		HistoryID uint `gorm:"primaryKey"`
+		Entry
+
+		ID       uint
+		TenantID uint
+		Title    string
+	}
+)
+
+func (OrderLine) CreateHistory() History {
+	return &OrderLineHistory{}
+}
+
+func (TenantDocument) CreateHistory() History {
+	return &TenantDocumentHistory{}
+}
+
+func (suite *PluginTestSuite) TestCompositePrimaryKey() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&OrderLine{}, &OrderLineHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&OrderLine{}, &OrderLineHistory{}))
+	}()
+
+	line := OrderLine{OrderID: 42, LineNo: 1, Product: "Book"}
+	suite.Require().NoError(suite.db.Create(&line).Error)
+
+	line.Product = "Pen"
+	suite.Require().NoError(suite.db.Save(&line).Error)
+
+	other := OrderLine{OrderID: 42, LineNo: 2, Product: "Ink"}
+	suite.Require().NoError(suite.db.Create(&other).Error)
+
+	entries, err := FindObjectHistory(suite.db, &OrderLine{}, CompositeKey{uint(42), uint(1)})
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+
+	h := entries[1].(*OrderLineHistory)
+	suite.Equal("[42,1]", h.ObjectID)
+	suite.Equal(ActionUpdate, h.Action)
+	suite.Equal("Pen", h.Product)
+	suite.Zero(h.OrderID)
+	suite.Zero(h.LineNo)
+
+	latest, err := LatestVersion(suite.db, &OrderLine{}, CompositeKey{uint(42), uint(1)})
+	suite.Require().NoError(err)
+	suite.Equal(h.Version, latest)
+}
+
+func (suite *PluginTestSuite) TestCompositePrimaryKeySliceCreate() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&OrderLine{}, &OrderLineHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&OrderLine{}, &OrderLineHistory{}))
+	}()
+
+	lines := []OrderLine{{OrderID: 42, LineNo: 1, Product: "Ink"}, {OrderID: 42, LineNo: 2, Product: "Paper"}}
+	suite.Require().NoError(suite.db.Create(&lines).Error)
+
+	var ids []string
+	suite.Require().NoError(suite.db.Model(&OrderLineHistory{}).Order("id asc").Pluck("object_id", &ids).Error)
+	suite.Equal([]string{"[42,1]", "[42,2]"}, ids)
+}
+
+func (suite *PluginTestSuite) TestCompositePrimaryKeyWithID() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&TenantDocument{}, &TenantDocumentHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&TenantDocument{}, &TenantDocumentHistory{}))
+	}()
+
+	docs := []TenantDocument{{ID: 5, TenantID: 1, Title: "Draft"}, {ID: 5, TenantID: 2, Title: "Notes"}}
+	suite.Require().NoError(suite.db.Create(&docs).Error)
+
+	entries, err := FindObjectHistory(suite.db, &TenantDocument{}, CompositeKey{uint(5), uint(2)})
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 1)
+
+	h := entries[0].(*TenantDocumentHistory)
+	suite.Equal("[5,2]", h.ObjectID)
+	suite.Equal("Notes", h.Title)
+	suite.Zero(h.ID)
+	suite.Zero(h.TenantID)
+}
+
+func TestCompositeKeyString(t *testing.T) {
+	require.Equal(t, `[42,"a",1.5]`, CompositeKey{42, "a", 1.5}.String())
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// processMaps records the history of the records created from maps, e.g.
+// db.Model(&Person{}).Create(map[string]interface{}{"FirstName": "John"}). The maps only hold the values set by
+// the caller and the primary key returned by the database, so the records are loaded back by their primary keys.
+func (p *Plugin) processMaps(v reflect.Value, action Action, db *gorm.DB) ([]History, error) {
+	s := db.Statement.Schema
+	if _, ok := reflect.New(s.ModelType).Interface().(Recordable); !ok {
+		return nil, nil
+	}
+
+	var maps []map[string]interface{}
+	if v.Kind() == reflect.Map {
+		if m, ok := v.Interface().(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	} else {
+		for i := 0; i < v.Len(); i++ {
+			if m, ok := reflect.Indirect(v.Index(i)).Interface().(map[string]interface{}); ok && m != nil {
+				maps = append(maps, m)
+			}
+		}
+	}
+
+	keys, err := mapPrimaryKeys(s, maps)
+	if err != nil {
+		return nil, err
+	}
+
+	var hs []History
+	for _, key := range keys {
+		obj := reflect.New(s.ModelType)
+		tx := db.Session(&gorm.Session{NewDB: true}).Unscoped()
+		for i, field := range s.PrimaryFields {
+			tx = tx.Where(clause.Eq{
+				Column: clause.Column{Table: s.Table, Name: field.DBName},
+				Value:  key[i],
+			})
+		}
+
+		if err := tx.First(obj.Interface()).Error; err != nil {
+			return nil, fmt.Errorf("error loading the record created from a map: %w", err)
+		}
+
+		h, isRecordable, err := p.processStruct(obj.Elem(), action, db)
+		if err != nil {
+			return nil, err
+		}
+
+		if isRecordable {
+			hs = append(hs, h)
+		}
+	}
+
+	return hs, nil
+}
+
+// mapPrimaryKeys returns the primary keys of the records created from maps. When the database returns the
+// generated keys, gorm stores them in the maps, or appends them as maps of their own when a pointer to a slice of
+// maps is created, in the order of the created maps.
+func mapPrimaryKeys(s *schema.Schema, maps []map[string]interface{}) ([]CompositeKey, error) {
+	var records, returned []map[string]interface{}
+	for _, m := range maps {
+		if isPrimaryKeyMap(s, m) {
+			returned = append(returned, m)
+		} else {
+			records = append(records, m)
+		}
+	}
+
+	if len(records) == 0 {
+		records, returned = returned, nil
+	}
+
+	keys := make([]CompositeKey, 0, len(records))
+	for _, m := range records {
+		key, ok := mapPrimaryKey(s, m)
+		if !ok && len(returned) > 0 {
+			key, ok = mapPrimaryKey(s, returned[0])
+			returned = returned[1:]
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("not able to determine the primary key of a record created from a map: %w", ErrUnsupportedOperation)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func mapPrimaryKey(s *schema.Schema, m map[string]interface{}) (CompositeKey, bool) {
+	if len(s.PrimaryFields) == 0 {
+		return nil, false
+	}
+
+	key := make(CompositeKey, len(s.PrimaryFields))
+	for i, field := range s.PrimaryFields {
+		value, ok := m[field.Name]
+		if !ok {
+			value, ok = m[field.DBName]
+		}
+
+		if !ok || value == nil || reflect.ValueOf(value).IsZero() {
+			return nil, false
+		}
+
+		key[i] = value
+	}
+
+	return key, true
+}
+
+// isPrimaryKeyMap reports whether m only holds primary key values, like the maps gorm appends the returned keys in.
+func isPrimaryKeyMap(s *schema.Schema, m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+
+	for k := range m {
+		field := s.LookUpField(k)
+		if field == nil || !field.PrimaryKey {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isMapValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Map {
+		return true
+	}
+
+	return v.Type().Elem().Kind() == reflect.Map ||
+		(v.Type().Elem().Kind() == reflect.Ptr && v.Type().Elem().Elem().Kind() == reflect.Map)
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		return v.IsNil()
+	}
+
+	return !v.IsValid()
+}
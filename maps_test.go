@@ -0,0 +1,94 @@
+package history
+
+func (suite *PluginTestSuite) TestCreateFromMap() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	m := map[string]interface{}{"FirstName": "John", "LastName": "Doe"}
+	suite.Require().NoError(suite.db.Model(&Person{}).Create(m).Error)
+
+	var p Person
+	suite.Require().NoError(suite.db.First(&p, "first_name = ?", "John").Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal(ActionCreate, entry.Action)
+	suite.Equal("John", entry.FirstName)
+	suite.Equal("Doe", entry.LastName)
+}
+
+func (suite *PluginTestSuite) TestCreateFromSliceOfMaps() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	maps := []map[string]interface{}{{"FirstName": "John"}, {"FirstName": "Jane"}}
+	suite.Require().NoError(suite.db.Model(&Person{}).Create(&maps).Error)
+
+	var names []string
+	err := suite.db.Model(&PersonHistory{}).Order("id asc").Pluck("first_name", &names).Error
+	suite.Require().NoError(err)
+	suite.Equal([]string{"John", "Jane"}, names)
+}
+
+func (suite *PluginTestSuite) TestCreateFromMapPointer() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	m := map[string]interface{}{"FirstName": "John"}
+	suite.Require().NoError(suite.db.Model(&Person{}).Create(&m).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", m["id"]).Error)
+	suite.Equal(ActionCreate, entry.Action)
+	suite.Equal("John", entry.FirstName)
+}
+
+func (suite *PluginTestSuite) TestCreateArray() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	people := [2]Person{{FirstName: "John"}, {FirstName: "Jane"}}
+	suite.Require().NoError(suite.db.Create(&people).Error)
+
+	for _, p := range people {
+		var entry PersonHistory
+		suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+		suite.Equal(p.FirstName, entry.FirstName)
+	}
+}
+
+func (suite *PluginTestSuite) TestCreateArrayOfPointers() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	people := [2]*Person{{FirstName: "John"}, {FirstName: "Jane"}}
+	suite.Require().NoError(suite.db.Create(&people).Error)
+
+	for _, p := range people {
+		var count int64
+		suite.Require().NoError(suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Count(&count).Error)
+		suite.EqualValues(1, count)
+	}
+}
+
+func (suite *PluginTestSuite) TestCreateWithNilElements() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	people := []*Person{{FirstName: "John"}, nil}
+	suite.Require().NotPanics(func() {
+		suite.Error(suite.db.Create(&people).Error)
+	})
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Count(&count).Error)
+	suite.Zero(count)
+}
+
+func (suite *PluginTestSuite) TestRecordWithNilElements() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(DisableFor(suite.db, &Person{}).Create(&p).Error)
+
+	suite.Require().NoError(Record(suite.db, []*Person{&p, nil}, ActionUpdate))
+	suite.assertHistoryCount(p.ID, 1)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Count(&count).Error)
+	suite.EqualValues(1, count)
+}
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const metadataOptionKey metadataOptionCtxKey = pluginName + ":metadata"
+
+var (
+	_ driver.Valuer   = Metadata(nil)
+	_ MetadataHistory = (*MetadataEntry)(nil)
+)
+
+type (
+	metadataOptionCtxKey string
+
+	// Metadata holds arbitrary values attached to history entries, e.g. the request ID or the IP address of the
+	// client. It is stored as a JSON object.
+	Metadata map[string]interface{}
+
+	MetadataHistory interface {
+		SetHistoryMetadata(metadata Metadata)
+	}
+
+	// MetadataEntry holds the column of MetadataHistory. Embed it next to Entry to record metadata.
+	MetadataEntry struct {
+		Metadata Metadata `gorm:"type:text"`
+	}
+)
+
+// SetMetadata merges metadata into the metadata set on db by previous calls, a key set again overriding the
+// previous value.
+func SetMetadata(db *gorm.DB, metadata Metadata) *gorm.DB {
+	current, _ := GetMetadata(db)
+	merged := current.merge(metadata)
+	ctx := context.WithValue(db.Statement.Context, metadataOptionKey, merged)
+
+	return db.WithContext(ctx).Set(string(metadataOptionKey), merged)
+}
+
+func GetMetadata(db *gorm.DB) (Metadata, bool) {
+	value, ok := db.Get(string(metadataOptionKey))
+	if !ok {
+		return MetadataFromContext(db.Statement.Context)
+	}
+
+	metadata, ok := value.(Metadata)
+
+	return metadata, ok
+}
+
+// ContextWithMetadata returns a copy of ctx carrying metadata merged into the metadata ctx already carries, which
+// is recorded by the statements run with db.WithContext(ctx), the same as with SetMetadata.
+func ContextWithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	current, _ := MetadataFromContext(ctx)
+
+	return context.WithValue(ctx, metadataOptionKey, current.merge(metadata))
+}
+
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	metadata, ok := ctx.Value(metadataOptionKey).(Metadata)
+
+	return metadata, ok
+}
+
+func (m Metadata) merge(other Metadata) Metadata {
+	merged := make(Metadata, len(m)+len(other))
+	for k, v := range m {
+		merged[k] = v
+	}
+
+	for k, v := range other {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (m *Metadata) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported metadata value type %T", value)
+	}
+
+	if len(b) == 0 {
+		*m = nil
+
+		return nil
+	}
+
+	return json.Unmarshal(b, m)
+}
+
+func (e *MetadataEntry) SetHistoryMetadata(metadata Metadata) {
+	e.Metadata = metadata
+}
@@ -0,0 +1,20 @@
+package history
+
+func (suite *PluginTestSuite) TestSetMetadata() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	db := SetMetadata(suite.db, Metadata{"request_id": "r-1", "ip": "10.0.0.1"})
+	db = SetMetadata(db, Metadata{"ip": "10.0.0.2", "tenant": "acme"})
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", p.ID).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(Metadata{"request_id": "r-1", "ip": "10.0.0.2", "tenant": "acme"}, entries[0].Metadata)
+	suite.Nil(entries[1].Metadata)
+}
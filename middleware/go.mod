@@ -0,0 +1,12 @@
+module github.com/vcraescu/gorm-history/v2/middleware
+
+go 1.13
+
+require (
+	github.com/stretchr/testify v1.7.0
+	github.com/vcraescu/gorm-history/v2 v2.0.1-0.20261018174918-4ac7a04951fa
+	google.golang.org/grpc v1.43.0
+)
+
+// The require above pins the first commit having the context helpers; the replace is only for local development.
+replace github.com/vcraescu/gorm-history/v2 => ../
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	history "github.com/vcraescu/gorm-history/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type (
+	// GRPCUserExtractor returns the user of the call of the fullMethod RPC, e.g. from the incoming metadata of ctx.
+	GRPCUserExtractor func(ctx context.Context, fullMethod string) (history.User, bool)
+
+	GRPCSourceExtractor func(ctx context.Context, fullMethod string) (history.Source, bool)
+
+	GRPCOption func(c *grpcConfig)
+
+	grpcConfig struct {
+		userExtractor   GRPCUserExtractor
+		sourceExtractor GRPCSourceExtractor
+		requestIDKey    string
+	}
+
+	serverStream struct {
+		grpc.ServerStream
+		ctx context.Context
+	}
+)
+
+// WithGRPCUserExtractor sets the function returning the user of a call. Without one, no user is put in the call
+// context.
+func WithGRPCUserExtractor(fn GRPCUserExtractor) GRPCOption {
+	return func(c *grpcConfig) {
+		c.userExtractor = fn
+	}
+}
+
+// WithGRPCSourceExtractor replaces the default source of a call, whose ID is the value of the request ID incoming
+// metadata key and whose type is SourceTypeGRPC. Calls without that key get no source.
+func WithGRPCSourceExtractor(fn GRPCSourceExtractor) GRPCOption {
+	return func(c *grpcConfig) {
+		c.sourceExtractor = fn
+	}
+}
+
+func WithRequestIDMetadataKey(key string) GRPCOption {
+	return func(c *grpcConfig) {
+		c.requestIDKey = strings.ToLower(key)
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor putting the user and the source of each unary call in its
+// context, along with the client address and the method of the call as history metadata.
+func UnaryServerInterceptor(opts ...GRPCOption) grpc.UnaryServerInterceptor {
+	c := newGRPCConfig(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(c.withIdentity(ctx, info.FullMethod), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(opts ...GRPCOption) grpc.StreamServerInterceptor {
+	c := newGRPCConfig(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          c.withIdentity(ss.Context(), info.FullMethod),
+		})
+	}
+}
+
+func newGRPCConfig(opts []GRPCOption) *grpcConfig {
+	c := &grpcConfig{requestIDKey: strings.ToLower(DefaultRequestIDHeader)}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.sourceExtractor == nil {
+		c.sourceExtractor = c.defaultSource
+	}
+
+	return c
+}
+
+func (c *grpcConfig) withIdentity(ctx context.Context, fullMethod string) context.Context {
+	var (
+		user    history.User
+		hasUser bool
+	)
+	if c.userExtractor != nil {
+		user, hasUser = c.userExtractor(ctx, fullMethod)
+	}
+
+	source, hasSource := c.sourceExtractor(ctx, fullMethod)
+	md := history.Metadata{MetadataMethod: fullMethod}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		md[MetadataClientAddress] = p.Addr.String()
+	}
+
+	return withIdentity(ctx, user, hasUser, source, hasSource, md)
+}
+
+func (c *grpcConfig) defaultSource(ctx context.Context, _ string) (history.Source, bool) {
+	source := history.Source{Type: SourceTypeGRPC}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(c.requestIDKey); len(values) > 0 {
+			source.ID = values[0]
+		}
+	}
+
+	return source, source.ID != ""
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	history "github.com/vcraescu/gorm-history/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	calls := make(chan context.Context, 2)
+	capture := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		calls <- ctx
+
+		return handler(ctx, req)
+	}
+	captureStream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		calls <- ss.Context()
+
+		return nil
+	}
+
+	extractor := func(ctx context.Context, fullMethod string) (history.User, bool) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ids := md.Get("user-id")
+		if len(ids) == 0 {
+			return history.User{}, false
+		}
+
+		return history.User{ID: ids[0]}, true
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(WithGRPCUserExtractor(extractor)), capture),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(WithGRPCUserExtractor(extractor)), captureStream),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "user-id", "123", "x-request-id", "req-1")
+	client := healthpb.NewHealthClient(conn)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assertIdentity(t, <-calls, "/grpc.health.v1.Health/Check")
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, _ = stream.Recv()
+	assertIdentity(t, <-calls, "/grpc.health.v1.Health/Watch")
+}
+
+func assertIdentity(t *testing.T, ctx context.Context, method string) {
+	t.Helper()
+
+	user, ok := history.UserFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "123", user.ID)
+
+	source, ok := history.SourceFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, history.Source{ID: "req-1", Type: SourceTypeGRPC}, source)
+
+	md, ok := history.MetadataFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, method, md[MetadataMethod])
+	require.Equal(t, "bufconn", md[MetadataClientAddress])
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+
+	history "github.com/vcraescu/gorm-history/v2"
+)
+
+type (
+	HTTPUserExtractor func(r *http.Request) (history.User, bool)
+
+	HTTPSourceExtractor func(r *http.Request) (history.Source, bool)
+
+	HTTPOption func(c *httpConfig)
+
+	httpConfig struct {
+		userExtractor   HTTPUserExtractor
+		sourceExtractor HTTPSourceExtractor
+		requestIDHeader string
+	}
+)
+
+// WithHTTPUserExtractor sets the function returning the user of a request, e.g. from its session. Without one,
+// no user is put in the request context.
+func WithHTTPUserExtractor(fn HTTPUserExtractor) HTTPOption {
+	return func(c *httpConfig) {
+		c.userExtractor = fn
+	}
+}
+
+// WithHTTPSourceExtractor replaces the default source of a request, whose ID is the value of the request ID
+// header and whose type is SourceTypeHTTP. Requests without that header get no source.
+func WithHTTPSourceExtractor(fn HTTPSourceExtractor) HTTPOption {
+	return func(c *httpConfig) {
+		c.sourceExtractor = fn
+	}
+}
+
+func WithRequestIDHeader(name string) HTTPOption {
+	return func(c *httpConfig) {
+		c.requestIDHeader = name
+	}
+}
+
+// HTTP returns a net/http middleware putting the user and the source of each request in its context, along with
+// the client address and the method of the request as history metadata.
+func HTTP(opts ...HTTPOption) func(next http.Handler) http.Handler {
+	c := &httpConfig{requestIDHeader: DefaultRequestIDHeader}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.sourceExtractor == nil {
+		c.sourceExtractor = c.defaultSource
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				user    history.User
+				hasUser bool
+			)
+			if c.userExtractor != nil {
+				user, hasUser = c.userExtractor(r)
+			}
+
+			source, hasSource := c.sourceExtractor(r)
+			metadata := history.Metadata{
+				MetadataClientAddress: r.RemoteAddr,
+				MetadataMethod:        r.Method + " " + r.URL.Path,
+			}
+
+			ctx := withIdentity(r.Context(), user, hasUser, source, hasSource, metadata)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (c *httpConfig) defaultSource(r *http.Request) (history.Source, bool) {
+	id := r.Header.Get(c.requestIDHeader)
+
+	return history.Source{ID: id, Type: SourceTypeHTTP}, id != ""
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	history "github.com/vcraescu/gorm-history/v2"
+)
+
+func TestHTTP(t *testing.T) {
+	var (
+		user     history.User
+		source   history.Source
+		metadata history.Metadata
+	)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = history.UserFromContext(r.Context())
+		source, _ = history.SourceFromContext(r.Context())
+		metadata, _ = history.MetadataFromContext(r.Context())
+	})
+
+	extractor := func(r *http.Request) (history.User, bool) {
+		id := r.Header.Get("X-User-ID")
+
+		return history.User{ID: id}, id != ""
+	}
+	srv := httptest.NewServer(HTTP(WithHTTPUserExtractor(extractor))(next))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/people", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-User-ID", "123")
+	req.Header.Set(DefaultRequestIDHeader, "req-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Equal(t, history.User{ID: "123"}, user)
+	require.Equal(t, history.Source{ID: "req-1", Type: SourceTypeHTTP}, source)
+	require.Equal(t, "POST /people", metadata[MetadataMethod])
+	require.NotEmpty(t, metadata[MetadataClientAddress])
+}
+
+func TestHTTPWithoutUser(t *testing.T) {
+	var hasUser, hasSource bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasUser = history.UserFromContext(r.Context())
+		_, hasSource = history.SourceFromContext(r.Context())
+	})
+
+	rec := httptest.NewRecorder()
+	HTTP()(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.False(t, hasUser)
+	require.False(t, hasSource)
+}
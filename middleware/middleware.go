@@ -0,0 +1,33 @@
+// Package middleware puts the user and the source of the requests served over HTTP or gRPC in the request context,
+// so the changes made with db.WithContext(ctx) while serving them are recorded with their identity.
+package middleware
+
+import (
+	"context"
+
+	history "github.com/vcraescu/gorm-history/v2"
+)
+
+const (
+	DefaultRequestIDHeader = "X-Request-ID"
+	SourceTypeHTTP         = "http"
+	SourceTypeGRPC         = "grpc"
+	MetadataClientAddress  = "client_address"
+	MetadataMethod         = "method"
+)
+
+func withIdentity(ctx context.Context, user history.User, hasUser bool, source history.Source, hasSource bool, metadata history.Metadata) context.Context {
+	if hasUser {
+		ctx = history.ContextWithUser(ctx, user)
+	}
+
+	if hasSource {
+		ctx = history.ContextWithSource(ctx, source)
+	}
+
+	if len(metadata) > 0 {
+		ctx = history.ContextWithMetadata(ctx, metadata)
+	}
+
+	return ctx
+}
@@ -0,0 +1,144 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var ErrHistoryModelMismatch = errors.New("history model does not match its model")
+
+// AutoMigrate migrates the history models of models, which must have a column for every field of their model (other
+// than the primary key and the ignored fields) of the same type, and indexes them on (object_id, version) and
+// created_at. The models themselves are not migrated.
+func AutoMigrate(db *gorm.DB, models ...Recordable) error {
+	db = db.Session(&gorm.Session{NewDB: true})
+	for _, model := range models {
+		if err := autoMigrate(db, model); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func autoMigrate(db *gorm.DB, model Recordable) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	hs, err := parseHistorySchema(db, model)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := modelConfig(db, model)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyHistorySchema(stmt.Schema, hs, cfg); err != nil {
+		return err
+	}
+
+	h := historyModel(model.CreateHistory())
+	if err := db.AutoMigrate(h); err != nil {
+		return err
+	}
+
+	objectIDCol, err := lookUpColumn(hs, "ObjectID")
+	if err != nil {
+		return err
+	}
+
+	versionCol, err := lookUpColumn(hs, "Version")
+	if err != nil {
+		return err
+	}
+
+	createdAtCol, err := lookUpColumn(hs, "CreatedAt")
+	if err != nil {
+		return err
+	}
+
+	if err := createIndex(db, h, hs.Table, objectIDCol, versionCol); err != nil {
+		return err
+	}
+
+	return createIndex(db, h, hs.Table, createdAtCol)
+}
+
+func verifyHistorySchema(s, hs *schema.Schema, cfg *ModelConfig) error {
+	ignored := make(map[string]bool)
+	for _, name := range cfg.IgnoredFields {
+		ignored[name] = true
+	}
+
+	entryColumns := make(map[string]bool)
+	for name := range fieldTags {
+		if col, err := lookUpColumn(hs, name); err == nil {
+			entryColumns[col] = true
+		}
+	}
+
+	var problems []string
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || ignored[field.Name] || ignored[field.DBName] {
+			continue
+		}
+
+		hField := hs.LookUpField(field.Name)
+		if hField == nil || hField.DBName == "" {
+			problems = append(problems, fmt.Sprintf(`missing column "%s"`, field.DBName))
+
+			continue
+		}
+
+		if entryColumns[hField.DBName] {
+			continue
+		}
+
+		if indirectType(field.FieldType) != indirectType(hField.FieldType) {
+			problems = append(problems, fmt.Sprintf(
+				`column "%s" is %s, want %s`,
+				hField.DBName, hField.FieldType, field.FieldType,
+			))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s of %s: %s: %w", hs.Name, s.Name, strings.Join(problems, ", "), ErrHistoryModelMismatch)
+	}
+
+	return nil
+}
+
+func createIndex(db *gorm.DB, h interface{}, table string, columns ...string) error {
+	name := fmt.Sprintf("idx_%s_%s", table, strings.Join(columns, "_"))
+	if db.Migrator().HasIndex(h, name) {
+		return nil
+	}
+
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = db.Statement.Quote(col)
+	}
+
+	return db.Exec(fmt.Sprintf(
+		"CREATE INDEX %s ON %s (%s)",
+		db.Statement.Quote(name), db.Statement.Quote(table), strings.Join(quoted, ", "),
+	)).Error
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
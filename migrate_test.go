@@ -0,0 +1,65 @@
+package history
+
+import "errors"
+
+type (
+	Invoice struct {
+		ID     uint `gorm:"primaryKey"`
+		Number string
+		Total  int
+		Secret string `gorm-history-model:"-"`
+	}
+
+	InvoiceHistory struct {
+		ID uint `gorm:"primaryKey"`
+		Entry
+
+		Number string
+		Total  *int
+	}
+
+	Draft struct {
+		ID    uint `gorm:"primaryKey"`
+		Title string
+		Pages int
+	}
+
+	DraftHistory struct {
+		ID uint `gorm:"primaryKey"`
+		Entry
+
+		Title int
+	}
+)
+
+func (Invoice) CreateHistory() History {
+	return &InvoiceHistory{}
+}
+
+func (Draft) CreateHistory() History {
+	return &DraftHistory{}
+}
+
+func (suite *PluginTestSuite) TestAutoMigrate() {
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&InvoiceHistory{}))
+	}()
+
+	suite.Require().NoError(AutoMigrate(suite.db, &Invoice{}))
+	suite.Require().NoError(AutoMigrate(suite.db, &Invoice{}))
+
+	m := suite.db.Migrator()
+	suite.True(m.HasTable(&InvoiceHistory{}))
+	suite.False(m.HasTable(&Invoice{}))
+	suite.True(m.HasIndex(&InvoiceHistory{}, "idx_invoice_histories_object_id_version"))
+	suite.True(m.HasIndex(&InvoiceHistory{}, "idx_invoice_histories_created_at"))
+}
+
+func (suite *PluginTestSuite) TestAutoMigrateMismatch() {
+	err := AutoMigrate(suite.db, &Draft{})
+	suite.Require().Error(err)
+	suite.True(errors.Is(err, ErrHistoryModelMismatch))
+	suite.Contains(err.Error(), `column "title" is int, want string`)
+	suite.Contains(err.Error(), `missing column "pages"`)
+	suite.False(suite.db.Migrator().HasTable(&DraftHistory{}))
+}
@@ -12,16 +12,29 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 
 	"github.com/jinzhu/copier"
 )
 
 const (
-	pluginName                             = "gorm-history"
-	createCbName                           = pluginName + ":after_create"
-	updateCbName                           = pluginName + ":after_update"
-	disabledOptionKey disabledOptionCtxKey = pluginName + ":disabled"
+	pluginName                                   = "gorm-history"
+	createCbName                                 = pluginName + ":after_create"
+	updateCbName                                 = pluginName + ":after_update"
+	beforeCreateCbName                           = pluginName + ":before_create"
+	beforeUpdateCbName                           = pluginName + ":before_update"
+	checkVersionCbName                           = pluginName + ":check_version"
+	beforeAssociateCbName                        = pluginName + ":before_associate"
+	associateCbName                              = pluginName + ":after_associate"
+	beforeDissociateCbName                       = pluginName + ":before_dissociate"
+	dissociateCbName                             = pluginName + ":after_dissociate"
+	checkCreateReasonCbName                      = pluginName + ":check_create_reason"
+	checkUpdateReasonCbName                      = pluginName + ":check_update_reason"
+	beforeUpsertCbName                           = pluginName + ":before_upsert"
+	beginCreateCbName                            = pluginName + ":begin_create"
+	endCreateCbName                              = pluginName + ":end_create"
+	beginUpdateCbName                            = pluginName + ":begin_update"
+	endUpdateCbName                              = pluginName + ":end_update"
+	disabledOptionKey       disabledOptionCtxKey = pluginName + ":disabled"
 )
 
 var (
@@ -37,6 +50,10 @@ type (
 
 	CopyFunc func(r Recordable, h interface{}) error
 
+	// UserResolver returns the user of the changes made with ctx when none was set with SetUser or
+	// ContextWithUser, e.g. from the claims of an authenticated request.
+	UserResolver func(ctx context.Context) (User, bool)
+
 	callback func(db *gorm.DB)
 
 	Context struct {
@@ -50,49 +67,96 @@ type (
 	Option struct{}
 
 	Config struct {
-		VersionFunc VersionFunc
-		CopyFunc    CopyFunc
+		VersionFunc         VersionFunc
+		CopyFunc            CopyFunc
+		RetentionPolicies   []RetentionPolicy
+		PruneBatchSize      int
+		ArchiveStorage      ArchiveStorage
+		ArchiveFormat       ArchiveFormat
+		CompactionPolicies  []CompactionPolicy
+		StatementChangeSets bool
+		AssociationHistory  bool
+		RawActions          bool
+		RequiredReasons     []Recordable
+		UserResolver        UserResolver
+		models              []modelRegistration
 	}
 
 	ConfigFunc func(c *Config)
 
 	ULIDVersion struct {
-		entropy io.Reader
-		mu      sync.Mutex
+		entropy       io.Reader
+		mu            sync.Mutex
+		versionCreate bool
 	}
 
+	ULIDVersionOption func(v *ULIDVersion)
+
 	IsZeroer interface {
 		IsZero() bool
 	}
 
 	primaryKeyField struct {
-		name   string
+		names  []string
 		value  interface{}
 		isZero bool
 	}
 
 	Plugin struct {
-		versionFunc VersionFunc
-		copyFunc    CopyFunc
-		createCb    callback
-		updateCb    callback
+		versionFunc         VersionFunc
+		copyFunc            CopyFunc
+		retentionPolicies   []RetentionPolicy
+		pruneBatchSize      int
+		archiveStorage      ArchiveStorage
+		archiveFormat       ArchiveFormat
+		compactionPolicies  []CompactionPolicy
+		statementChangeSets bool
+		associationHistory  bool
+		rawActions          bool
+		requiredReasons     map[reflect.Type]bool
+		userResolver        UserResolver
+		modelRegistrations  []modelRegistration
+		models              *modelRegistry
+		createCb            callback
+		updateCb            callback
 	}
 )
 
 func New(configFuncs ...ConfigFunc) *Plugin {
 	version := NewULIDVersion()
 	cfg := &Config{
-		VersionFunc: version.Version,
-		CopyFunc:    DefaultCopyFunc,
+		VersionFunc:    version.Version,
+		CopyFunc:       DefaultCopyFunc,
+		PruneBatchSize: defaultPruneBatchSize,
 	}
 
 	for _, f := range configFuncs {
 		f(cfg)
 	}
 
+	if cfg.PruneBatchSize <= 0 {
+		cfg.PruneBatchSize = defaultPruneBatchSize
+	}
+
+	if cfg.ArchiveFormat == "" {
+		cfg.ArchiveFormat = ArchiveFormatJSONL
+	}
+
 	p := Plugin{
-		versionFunc: cfg.VersionFunc,
-		copyFunc:    cfg.CopyFunc,
+		versionFunc:         cfg.VersionFunc,
+		copyFunc:            cfg.CopyFunc,
+		retentionPolicies:   cfg.RetentionPolicies,
+		pruneBatchSize:      cfg.PruneBatchSize,
+		archiveStorage:      cfg.ArchiveStorage,
+		archiveFormat:       cfg.ArchiveFormat,
+		compactionPolicies:  cfg.CompactionPolicies,
+		statementChangeSets: cfg.StatementChangeSets,
+		associationHistory:  cfg.AssociationHistory,
+		rawActions:          cfg.RawActions,
+		requiredReasons:     requiredReasons(cfg.RequiredReasons),
+		userResolver:        cfg.UserResolver,
+		modelRegistrations:  cfg.models,
+		models:              newModelRegistry(),
 	}
 
 	return &p
@@ -110,10 +174,29 @@ func WithCopyFunc(fn CopyFunc) ConfigFunc {
 	}
 }
 
-func NewULIDVersion() *ULIDVersion {
+func WithUserResolver(fn UserResolver) ConfigFunc {
+	return func(c *Config) {
+		c.UserResolver = fn
+	}
+}
+
+func NewULIDVersion(opts ...ULIDVersionOption) *ULIDVersion {
 	entropy := ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
 
-	return &ULIDVersion{entropy: entropy}
+	v := &ULIDVersion{entropy: entropy}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// WithCreateVersions makes ULIDVersion generate versions for ActionCreate entries too. By default they are left
+// empty.
+func WithCreateVersions() ULIDVersionOption {
+	return func(v *ULIDVersion) {
+		v.versionCreate = true
+	}
 }
 
 func Disable(db *gorm.DB) *gorm.DB {
@@ -136,28 +219,166 @@ func (p *Plugin) Name() string {
 }
 
 func (p *Plugin) Initialize(db *gorm.DB) error {
+	for _, m := range p.modelRegistrations {
+		if err := p.Register(m.model, m.opts...); err != nil {
+			return err
+		}
+	}
+
 	p.createCb = p.callback(ActionCreate)
 	p.updateCb = p.callback(ActionUpdate)
 
 	err := db.
+		Callback().
+		Create().
+		Before("gorm:save_before_associations").
+		Register(beforeCreateCbName, p.beginChangeSet)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Create().
+		Before("gorm:save_before_associations").
+		Register(beginCreateCbName, beginStatement)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Update().
+		Before("gorm:save_before_associations").
+		Register(beginUpdateCbName, beginStatement)
+	if err != nil {
+		return err
+	}
+
+	err = db.
 		Callback().
 		Create().
 		After("gorm:create").
+		Before("gorm:commit_or_rollback_transaction").
 		Register(createCbName, p.createCb)
 	if err != nil {
 		return err
 	}
 
-	return db.
+	err = db.
+		Callback().
+		Create().
+		Before("gorm:create").
+		Register(checkCreateReasonCbName, p.checkReason(ActionCreate))
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Create().
+		Before("gorm:create").
+		Register(beforeUpsertCbName, p.beforeUpsert)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Create().
+		Before("gorm:create").
+		Register(beforeAssociateCbName, p.beforeAssociate)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Create().
+		After("gorm:create").
+		Before("gorm:commit_or_rollback_transaction").
+		Register(associateCbName, p.afterAssociate)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Delete().
+		Before("gorm:delete").
+		Register(beforeDissociateCbName, p.beforeDissociate)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Delete().
+		After("gorm:delete").
+		Before("gorm:commit_or_rollback_transaction").
+		Register(dissociateCbName, p.afterDissociate)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Update().
+		Before("gorm:save_before_associations").
+		Register(beforeUpdateCbName, p.beginChangeSet)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Update().
+		Before("gorm:update").
+		Register(checkVersionCbName, p.checkVersion)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Update().
+		Before("gorm:update").
+		Register(checkUpdateReasonCbName, p.checkReason(ActionUpdate))
+	if err != nil {
+		return err
+	}
+
+	err = db.
 		Callback().
 		Update().
 		After("gorm:update").
+		Before("gorm:commit_or_rollback_transaction").
 		Register(updateCbName, p.updateCb)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Callback().
+		Create().
+		After(createCbName).
+		Before("gorm:commit_or_rollback_transaction").
+		Register(endCreateCbName, endStatement)
+	if err != nil {
+		return err
+	}
+
+	return db.
+		Callback().
+		Update().
+		After(updateCbName).
+		Before("gorm:commit_or_rollback_transaction").
+		Register(endUpdateCbName, endStatement)
 }
 
 func (p Plugin) callback(action Action) func(db *gorm.DB) {
 	return func(db *gorm.DB) {
-		if db.Statement.Schema == nil {
+		if db.Error != nil || db.Statement.Schema == nil {
 			return
 		}
 
@@ -165,53 +386,64 @@ func (p Plugin) callback(action Action) func(db *gorm.DB) {
 			return
 		}
 
-		v := db.Statement.ReflectValue
+		if action == ActionUpdate && p.associationHistory && isAssociationUpdate(db) {
+			return
+		}
 
-		switch v.Kind() {
-		case reflect.Struct:
-			h, isRecordable, err := p.processStruct(v, action, db)
-			if err != nil {
-				db.AddError(err)
-				return
-			}
+		if err := p.record(db, action); err != nil {
+			db.AddError(err)
+		}
+	}
+}
 
-			if !isRecordable {
-				return
-			}
+// record records the history of the value of the statement of db for action.
+func (p *Plugin) record(db *gorm.DB, action Action) error {
+	v := db.Statement.ReflectValue
 
-			if err := p.saveHistory(db, h); err != nil {
-				db.AddError(err)
-				return
-			}
-		case reflect.Slice:
-			hs, err := p.processSlice(v, action, db)
-			if err != nil {
-				db.AddError(err)
-				return
-			}
+	switch v.Kind() {
+	case reflect.Struct:
+		h, isRecordable, err := p.processStruct(v, action, db)
+		if err != nil {
+			return err
+		}
 
-			if len(hs) == 0 {
-				return
-			}
+		if !isRecordable {
+			return nil
+		}
 
-			if err := p.saveHistory(db, hs...); err != nil {
-				db.AddError(err)
-				return
-			}
+		return p.saveHistory(db, h)
+	case reflect.Map, reflect.Slice, reflect.Array:
+		var (
+			hs  []History
+			err error
+		)
+		if isMapValue(v) {
+			hs, err = p.processMaps(v, action, db)
+		} else {
+			hs, err = p.processSlice(v, action, db)
 		}
+		if err != nil {
+			return err
+		}
+
+		return p.saveHistory(db, hs...)
 	}
+
+	return nil
 }
 
 func (p *Plugin) saveHistory(db *gorm.DB, hs ...History) error {
-	if len(hs) == 0 {
-		return nil
-	}
-
-	db = db.Session(&gorm.Session{
-		NewDB: true,
-	})
 	for _, h := range hs {
-		if err := db.Omit(clause.Associations).Create(h).Error; err != nil {
+		store := p.models.store(h)
+		if store == nil {
+			if err := saveHistory(db, h); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := store.SaveHistory(db, h); err != nil {
 			return err
 		}
 	}
@@ -226,6 +458,20 @@ func (p *Plugin) processStruct(v reflect.Value, action Action, db *gorm.DB) (His
 		return nil, false, nil
 	}
 
+	cfg, err := p.ModelConfig(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	skip := func(action Action) bool {
+		return !cfg.Records(action) || IsDisabledFor(db, r, action)
+	}
+
+	// a create may turn out to be the update of an upsert, which is only known once the primary key is
+	if skip(action) && (action != ActionCreate || skip(ActionUpdate)) {
+		return nil, false, nil
+	}
+
 	pk, err := getPrimaryKeyValue(db, v)
 	if err != nil {
 		return nil, false, err
@@ -235,7 +481,25 @@ func (p *Plugin) processStruct(v reflect.Value, action Action, db *gorm.DB) (His
 		return nil, false, fmt.Errorf("not able to determine record primary key value: %w", ErrUnsupportedOperation)
 	}
 
-	h, err := p.newHistory(r, action, db, pk)
+	if action == ActionCreate {
+		var unchanged bool
+		if action, unchanged = upsertAction(db, pk); unchanged || skip(action) {
+			return nil, false, nil
+		}
+
+		if action == ActionUpdate {
+			row, err := reloadRecord(db, v, pk)
+			if err != nil {
+				return nil, false, err
+			}
+
+			r = row.Interface().(Recordable)
+		}
+	} else if skip(action) {
+		return nil, false, nil
+	}
+
+	h, err := p.newHistory(r, action, db, pk, cfg)
 	if err != nil {
 		return nil, true, err
 	}
@@ -247,6 +511,9 @@ func (p *Plugin) processSlice(v reflect.Value, action Action, db *gorm.DB) ([]Hi
 	var hs []History
 	for i := 0; i < v.Len(); i++ {
 		el := v.Index(i)
+		if isNil(el) {
+			continue
+		}
 
 		h, isRecordable, err := p.processStruct(el, action, db)
 		if err != nil {
@@ -263,33 +530,67 @@ func (p *Plugin) processSlice(v reflect.Value, action Action, db *gorm.DB) ([]Hi
 	return hs, nil
 }
 
-func (p *Plugin) newHistory(r Recordable, action Action, db *gorm.DB, pk *primaryKeyField) (History, error) {
+func (p *Plugin) newHistory(r Recordable, action Action, db *gorm.DB, pk *primaryKeyField, cfg *ModelConfig) (History, error) {
+	copyFunc, versionFunc := p.copyFunc, p.versionFunc
+	if cfg.CopyFunc != nil {
+		copyFunc = cfg.CopyFunc
+	}
+
+	if cfg.VersionFunc != nil {
+		versionFunc = cfg.VersionFunc
+	}
+
 	hist := r.CreateHistory()
-	ihist := makePtr(hist)
-	if err := p.copyFunc(r, ihist); err != nil {
+	model := historyModel(hist)
+	if err := copyFunc(r, makePtr(model)); err != nil {
 		return nil, err
 	}
 
-	if err := unsetStructField(hist, pk.name); err != nil {
-		return nil, err
+	for _, name := range pk.names {
+		if err := unsetStructField(model, name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range cfg.IgnoredFields {
+		if reflect.Indirect(reflect.ValueOf(model)).FieldByName(name).IsValid() {
+			if err := unsetStructField(model, name); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	if err := db.Statement.Parse(hist); err != nil {
+	if err := (&gorm.Statement{DB: db}).Parse(model); err != nil {
 		return nil, err
 	}
 
+	custom, hasCustom := GetAction(db)
+	if hasCustom {
+		if err := custom.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	ctx := &Context{
 		object:   r,
 		objectID: pk.value,
-		history:  hist.(History),
+		history:  hist,
 		action:   action,
 		db:       db,
 	}
-	version, err := p.versionFunc(ctx)
+	version, err := versionFunc(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error generating history version: %w", err)
 	}
 
+	if hasCustom {
+		if rh, ok := model.(RawActionHistory); ok && p.rawActions {
+			rh.SetHistoryRawAction(action)
+		}
+
+		action = custom
+	}
+
 	hist.SetHistoryAction(action)
 	hist.SetHistoryVersion(version)
 	hist.SetHistoryObjectID(pk.value)
@@ -298,21 +599,67 @@ func (p *Plugin) newHistory(r Recordable, action Action, db *gorm.DB, pk *primar
 		th.SetHistoryCreatedAt(db.NowFunc())
 	}
 
+	user, hasUser := p.getUser(db)
 	if bh, ok := hist.(BlameableHistory); ok {
-		if user, ok := GetUser(db); ok {
+		if hasUser {
 			bh.SetHistoryUserID(user.ID)
 			bh.SetHistoryUserEmail(user.Email)
 		}
 	}
 
-	if bh, ok := hist.(SourceableHistory); ok {
+	if ah, ok := model.(ActorHistory); ok {
+		if hasUser {
+			ah.SetHistoryUserName(user.Name)
+			ah.SetHistoryActorType(user.Type)
+
+			if user.OnBehalfOf != nil {
+				ah.SetHistoryOnBehalfOf(*user.OnBehalfOf)
+			}
+		}
+	}
+
+	if sh, ok := hist.(SourceableHistory); ok {
 		if source, ok := GetSource(db); ok {
-			bh.SetHistorySourceID(source.ID)
-			bh.SetHistorySourceType(source.Type)
+			sh.SetHistorySourceID(source.ID)
+			sh.SetHistorySourceType(source.Type)
+		}
+	}
+
+	if rh, ok := model.(ReasonableHistory); ok {
+		if reason, ok := GetReason(db); ok {
+			rh.SetHistoryReason(reason)
+		}
+	}
+
+	if mh, ok := model.(MetadataHistory); ok {
+		if metadata, ok := GetMetadata(db); ok {
+			mh.SetHistoryMetadata(metadata)
+		}
+	}
+
+	if ch, ok := model.(ChangeSetHistory); ok {
+		if id, ok := GetChangeSetID(db); ok {
+			ch.SetHistoryChangeSetID(id)
 		}
 	}
 
-	return hist.(History), nil
+	if th, ok := hist.(*TaggedHistory); ok && th.err != nil {
+		return nil, th.err
+	}
+
+	return hist, nil
+}
+
+func (p *Plugin) getUser(db *gorm.DB) (User, bool) {
+	if user, ok := GetUser(db); ok {
+		return user, true
+	}
+
+	if p.userResolver != nil {
+		return p.userResolver(db.Statement.Context)
+	}
+
+	return User{}, false
 }
 
 func (c *Context) Object() Recordable {
@@ -336,7 +683,7 @@ func (c *Context) DB() *gorm.DB {
 }
 
 func (v *ULIDVersion) Version(ctx *Context) (Version, error) {
-	if ctx.Action() == ActionCreate {
+	if ctx.Action() == ActionCreate && !v.versionCreate {
 		return "", nil
 	}
 
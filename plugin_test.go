@@ -1,6 +1,7 @@
 package history
 
 import (
+	"context"
 	"fmt"
 	"github.com/jinzhu/copier"
 	"github.com/stretchr/testify/require"
@@ -27,6 +28,11 @@ type (
 	PersonHistory struct {
 		gorm.Model
 		Entry
+		ActorEntry
+		ReasonEntry
+		MetadataEntry
+		ChangeSetEntry
+		RawActionEntry
 
 		FirstName string
 		LastName  string
@@ -44,12 +50,26 @@ type (
 	AddressHistory struct {
 		gorm.Model
 		Entry
+		ChangeSetEntry
 
 		Line1 string
 		Line2 string
 		City  string
 	}
 
+	Contact struct {
+		gorm.Model
+
+		Name string
+	}
+
+	ContactHistory struct {
+		gorm.Model
+		Entry
+
+		Name string
+	}
+
 	PluginTestSuite struct {
 		suite.Suite
 		db *gorm.DB
@@ -80,6 +100,10 @@ func (Address) CreateHistory() History {
 	return &AddressHistory{}
 }
 
+func (Contact) CreateHistory() History {
+	return &ContactHistory{}
+}
+
 func ExamplePlugin() {
 	type Person struct {
 		gorm.Model
@@ -167,7 +191,7 @@ func (suite *PluginTestSuite) SetupTest() {
 
 	suite.db = db.Session(&gorm.Session{})
 
-	err = suite.db.AutoMigrate(Person{}, PersonHistory{}, Address{}, AddressHistory{})
+	err = suite.db.AutoMigrate(Person{}, PersonHistory{}, Address{}, AddressHistory{}, Post{}, PostHistory{}, Tag{}, ChangeSet{})
 	if err != nil {
 		panic(err)
 	}
@@ -175,10 +199,15 @@ func (suite *PluginTestSuite) SetupTest() {
 
 func (suite *PluginTestSuite) TearDownTest() {
 	db := suite.db.Session(&gorm.Session{AllowGlobalUpdate: true})
-	db.Delete(&Person{})
-	db.Delete(&PersonHistory{})
-	db.Delete(&Address{})
-	db.Delete(&AddressHistory{})
+	db.Unscoped().Delete(&Person{})
+	db.Unscoped().Delete(&PersonHistory{})
+	db.Unscoped().Delete(&Address{})
+	db.Unscoped().Delete(&AddressHistory{})
+	db.Exec("DELETE FROM post_tags")
+	db.Unscoped().Delete(&Post{})
+	db.Unscoped().Delete(&PostHistory{})
+	db.Unscoped().Delete(&Tag{})
+	db.Delete(&ChangeSet{})
 }
 
 func (suite *PluginTestSuite) TestDefaultVersionFunc() {
@@ -485,6 +514,100 @@ func (suite *PluginTestSuite) TestUserAndSource() {
 	suite.Require().EqualValues(1, count)
 }
 
+func (suite *PluginTestSuite) TestActor() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	user := User{
+		ID:    "admin-1",
+		Email: "admin@doe.com",
+		Name:  "Admin",
+		Type:  ActorHuman,
+		OnBehalfOf: &User{
+			ID:    "123",
+			Email: "john@doe.com",
+		},
+	}
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(SetUser(suite.db, user).Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal("admin-1", entry.UserID)
+	suite.Equal("Admin", entry.UserName)
+	suite.Equal(ActorHuman, entry.ActorType)
+	suite.Equal("123", entry.OnBehalfOfID)
+	suite.Equal("john@doe.com", entry.OnBehalfOfEmail)
+}
+
+func (suite *PluginTestSuite) TestEntryKeepsItsColumns() {
+	suite.Require().NoError(suite.db.AutoMigrate(&Contact{}))
+	err := suite.db.Exec(`CREATE TABLE contact_histories (
+		id integer PRIMARY KEY AUTOINCREMENT, created_at datetime, updated_at datetime, deleted_at datetime,
+		version char(26), object_id text, action varchar(24), user_id varchar(255), user_email varchar(255),
+		source_id varchar(255), source_type varchar(255), name text
+	)`).Error
+	suite.Require().NoError(err)
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&Contact{}, &ContactHistory{}))
+	}()
+
+	suite.Require().NoError(suite.db.Use(New(WithStatementChangeSets(), WithRawActions())))
+
+	db := SetUser(suite.db, User{ID: "1", Name: "Admin", Type: ActorHuman, OnBehalfOf: &User{ID: "2"}})
+	db = SetReason(db, "import")
+	db = SetMetadata(db, Metadata{"request_id": "r-1"})
+	db = SetAction(db, "import")
+
+	c := Contact{Name: "John"}
+	suite.Require().NoError(db.Create(&c).Error)
+
+	var entry ContactHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", c.ID).Error)
+	suite.Equal(Action("import"), entry.Action)
+	suite.Equal("1", entry.UserID)
+	suite.Equal("John", entry.Name)
+}
+
+func (suite *PluginTestSuite) TestContextWithUserAndSource() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	ctx := ContextWithUser(context.Background(), User{ID: "123"})
+	ctx = ContextWithSource(ctx, Source{ID: "job-1", Type: "cron"})
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.WithContext(ctx).Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal("123", entry.UserID)
+	suite.Equal("job-1", entry.SourceID)
+	suite.Equal("cron", entry.SourceType)
+}
+
+func (suite *PluginTestSuite) TestUserResolver() {
+	type claimsCtxKey struct{}
+
+	resolver := func(ctx context.Context) (User, bool) {
+		id, ok := ctx.Value(claimsCtxKey{}).(string)
+
+		return User{ID: id}, ok
+	}
+	suite.Require().NoError(suite.db.Use(New(WithUserResolver(resolver))))
+
+	ctx := context.WithValue(context.Background(), claimsCtxKey{}, "resolved")
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.WithContext(ctx).Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(SetUser(suite.db.WithContext(ctx), User{ID: "explicit"}).Save(&p).Error)
+
+	var ids []string
+	err := suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Order("id asc").Pluck("user_id", &ids).Error
+	suite.Require().NoError(err)
+	suite.Equal([]string{"resolved", "explicit"}, ids)
+}
+
 func (suite *PluginTestSuite) TestDisabled() {
 	plugin := New()
 	if err := suite.db.Use(plugin); err != nil {
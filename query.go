@@ -0,0 +1,65 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// FindObjectHistory returns the history entries of the object r identified by objectID, ordered by version. The
+// entries of the history models wrapped in a TaggedHistory are wrapped too.
+func FindObjectHistory(db *gorm.DB, r Recordable, objectID interface{}) ([]History, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+	s, err := parseHistorySchema(db, r)
+	if err != nil {
+		return nil, err
+	}
+
+	objectIDCol, err := lookUpColumn(s, "ObjectID")
+	if err != nil {
+		return nil, err
+	}
+
+	versionCol, err := lookUpColumn(s, "Version")
+	if err != nil {
+		return nil, err
+	}
+
+	rows := reflect.New(reflect.SliceOf(reflect.PtrTo(s.ModelType)))
+	err = db.
+		Unscoped().
+		Where(fmt.Sprintf("%s = ?", db.Statement.Quote(objectIDCol)), objectIDValue(s, objectID)).
+		Order(fmt.Sprintf("%s, %s", db.Statement.Quote(versionCol), db.Statement.Quote(s.PrioritizedPrimaryField.DBName))).
+		Find(rows.Interface()).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	h := r.CreateHistory()
+	entries := make([]History, rows.Elem().Len())
+	for i := range entries {
+		entries[i] = historyEntry(h, rows.Elem().Index(i).Interface())
+	}
+
+	return entries, nil
+}
+
+// objectIDValue converts objectID to the type of the object ID field of the history model s: the string
+// of Entry, or the type of the primary key of the object for the models embedding TypedEntry.
+func objectIDValue(s *schema.Schema, objectID interface{}) interface{} {
+	field := taggedField(s, TagObjectID)
+	if field == nil {
+		field = s.LookUpField("ObjectID")
+	}
+
+	if field != nil && field.FieldType.Kind() == reflect.String {
+		if _, ok := objectID.(string); !ok {
+			return fmt.Sprintf("%v", objectID)
+		}
+	}
+
+	return objectID
+}
@@ -0,0 +1,64 @@
+package history
+
+import "gorm.io/gorm"
+
+type (
+	Item struct {
+		gorm.Model
+
+		Name string
+	}
+
+	ItemHistory struct {
+		ID uint `gorm:"primaryKey"`
+		TypedEntry
+
+		ObjectID uint `gorm:"index" gorm-history:"objectID"`
+		Name     string
+	}
+)
+
+func (Item) CreateHistory() History {
+	return NewTaggedHistory(&ItemHistory{})
+}
+
+func (suite *PluginTestSuite) TestTypedObjectID() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&Item{}, &ItemHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&Item{}, &ItemHistory{}))
+	}()
+
+	items := []Item{{Name: "first"}, {Name: "second"}}
+	suite.Require().NoError(suite.db.Create(&items).Error)
+
+	items[1].Name = "third"
+	suite.Require().NoError(suite.db.Save(&items[1]).Error)
+
+	var ids []uint
+	err := suite.db.Model(&ItemHistory{}).Where("object_id > ?", items[0].ID).Order("id asc").Pluck("object_id", &ids).Error
+	suite.Require().NoError(err)
+	suite.Equal([]uint{items[1].ID, items[1].ID}, ids)
+
+	entries, err := FindObjectHistory(suite.db, &Item{}, items[1].ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+	entry := entries[1].(*TaggedHistory).Model().(*ItemHistory)
+	suite.Equal("third", entry.Name)
+	suite.Equal(ActionUpdate, entry.Action)
+
+	latest, err := LatestVersion(suite.db, &Item{}, items[1].ID)
+	suite.Require().NoError(err)
+	suite.Equal(entry.Version, latest)
+}
+
+func (suite *PluginTestSuite) TestFindObjectHistory() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := suite.createPersonWithUpdates(2)
+
+	entries, err := FindObjectHistory(suite.db, &Person{}, p.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 3)
+	suite.Equal(ActionCreate, entries[0].(*PersonHistory).Action)
+}
@@ -0,0 +1,104 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+const reasonOptionKey reasonOptionCtxKey = pluginName + ":reason"
+
+var (
+	ErrReasonRequired = errors.New("history reason is required")
+
+	_ ReasonableHistory = (*ReasonEntry)(nil)
+)
+
+type (
+	reasonOptionCtxKey string
+
+	// ReasonEntry holds the column of ReasonableHistory. Embed it next to Entry to record reasons.
+	ReasonEntry struct {
+		Reason string `gorm:"type:text"`
+	}
+)
+
+// WithRequiredReason makes the creates and updates of models fail with ErrReasonRequired when no reason was set
+// with SetReason.
+func WithRequiredReason(models ...Recordable) ConfigFunc {
+	return func(c *Config) {
+		c.RequiredReasons = append(c.RequiredReasons, models...)
+	}
+}
+
+func SetReason(db *gorm.DB, reason string) *gorm.DB {
+	ctx := context.WithValue(db.Statement.Context, reasonOptionKey, reason)
+
+	return db.WithContext(ctx).Set(string(reasonOptionKey), reason)
+}
+
+func GetReason(db *gorm.DB) (string, bool) {
+	value, ok := db.Get(string(reasonOptionKey))
+	if !ok {
+		value := db.Statement.Context.Value(reasonOptionKey)
+		reason, ok := value.(string)
+
+		return reason, ok
+	}
+
+	reason, ok := value.(string)
+
+	return reason, ok
+}
+
+func (p *Plugin) checkReason(action Action) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+
+		r, ok := reflect.New(db.Statement.Schema.ModelType).Interface().(Recordable)
+		if !ok {
+			return
+		}
+
+		if err := p.requireReason(db, r, action); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// requireReason returns ErrReasonRequired when the history entry of r for action, which is recorded by the
+// statements run through db, requires a reason which was not set.
+func (p *Plugin) requireReason(db *gorm.DB, r Recordable, action Action) error {
+	t, err := modelType(r)
+	if err != nil || !p.requiredReasons[t] || IsDisabledFor(db, r, action) {
+		return nil
+	}
+
+	if cfg, err := p.ModelConfig(r); err == nil && !cfg.Records(action) {
+		return nil
+	}
+
+	if reason, ok := GetReason(db); ok && reason != "" {
+		return nil
+	}
+
+	return fmt.Errorf("%w for %s", ErrReasonRequired, t.Name())
+}
+
+func requiredReasons(models []Recordable) map[reflect.Type]bool {
+	types := make(map[reflect.Type]bool, len(models))
+	for _, model := range models {
+		types[reflect.Indirect(reflect.ValueOf(model)).Type()] = true
+	}
+
+	return types
+}
+
+func (e *ReasonEntry) SetHistoryReason(reason string) {
+	e.Reason = reason
+}
@@ -0,0 +1,55 @@
+package history
+
+import "errors"
+
+func (suite *PluginTestSuite) TestSetReason() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(SetReason(suite.db, "ticket-1234: customer request").Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal("ticket-1234: customer request", entry.Reason)
+}
+
+func (suite *PluginTestSuite) TestRequiredReason() {
+	suite.Require().NoError(suite.db.Use(New(WithRequiredReason(&Person{}))))
+
+	p := Person{FirstName: "John"}
+	err := suite.db.Create(&p).Error
+	suite.True(errors.Is(err, ErrReasonRequired))
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&Person{}).Count(&count).Error)
+	suite.Zero(count)
+
+	suite.Require().NoError(SetReason(suite.db, "import").Create(&p).Error)
+
+	p.FirstName = "Jane"
+	err = suite.db.Save(&p).Error
+	suite.True(errors.Is(err, ErrReasonRequired))
+
+	a := Address{Line1: "Line 1"}
+	suite.Require().NoError(suite.db.Create(&a).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Find(&entries, "object_id = ?", p.ID).Error)
+	suite.Require().Len(entries, 1)
+	suite.Equal("import", entries[0].Reason)
+}
+
+func (suite *PluginTestSuite) TestRequiredReasonDisabled() {
+	suite.Require().NoError(suite.db.Use(New(WithRequiredReason(&Person{}))))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(DisableFor(suite.db, &Person{}).Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(DisableActions(suite.db, ActionUpdate).Save(&p).Error)
+
+	err := DisableActions(suite.db, ActionCreate).Save(&p).Error
+	suite.True(errors.Is(err, ErrReasonRequired))
+
+	suite.assertHistoryCount(p.ID, 0)
+}
@@ -0,0 +1,53 @@
+package history
+
+import (
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// Record records the history of value, a recordable model or a slice of them, for action through the same pipeline
+// as the callbacks of the plugin registered on db, e.g. after changing the record with db.Exec. The user, source,
+// reason, metadata and change set set on db are recorded, and value is recorded as it is, so it must hold the
+// state of the record after the change. Like the callbacks, Record fails with ErrReasonRequired when a required
+// reason is not set, and updates fail with a VersionConflictError when the version expected through ExpectVersion
+// is not the latest one; run the change and Record in one transaction for the row lock to hold.
+func Record(db *gorm.DB, value interface{}, action Action) error {
+	p, err := getPlugin(db)
+	if err != nil {
+		return err
+	}
+
+	if err := action.Validate(); err != nil {
+		return err
+	}
+
+	if value == nil {
+		return errors.New("recorded value is not set")
+	}
+
+	tx := db.Session(&gorm.Session{}).Model(value)
+	if err := tx.Statement.Parse(value); err != nil {
+		return err
+	}
+
+	tx.Statement.ReflectValue = reflect.Indirect(reflect.ValueOf(value))
+	if IsDisabled(tx) {
+		return nil
+	}
+
+	if r, ok := reflect.New(tx.Statement.Schema.ModelType).Interface().(Recordable); ok {
+		if err := p.requireReason(tx, r, action); err != nil {
+			return err
+		}
+	}
+
+	if action == ActionUpdate {
+		if err := checkExpectedVersion(tx); err != nil {
+			return err
+		}
+	}
+
+	return p.record(tx, action)
+}
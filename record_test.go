@@ -0,0 +1,78 @@
+package history
+
+import "errors"
+
+func (suite *PluginTestSuite) TestRecord() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	err := suite.db.Exec("UPDATE people SET first_name = ? WHERE id = ?", "Jane", p.ID).Error
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.db.First(&p, p.ID).Error)
+	suite.Require().NoError(Record(SetUser(suite.db, User{ID: "123"}), &p, ActionUpdate))
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", p.ID).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(ActionUpdate, entries[1].Action)
+	suite.Equal("Jane", entries[1].FirstName)
+	suite.Equal("123", entries[1].UserID)
+	suite.NotZero(entries[1].Version)
+}
+
+func (suite *PluginTestSuite) TestRecordSlice() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	people := []Person{{FirstName: "John"}, {FirstName: "Jane"}}
+	suite.Require().NoError(Disable(suite.db).Create(&people).Error)
+	suite.Require().NoError(Record(suite.db, people, ActionCreate))
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Where("action = ?", ActionCreate).Count(&count).Error)
+	suite.EqualValues(2, count)
+}
+
+func (suite *PluginTestSuite) TestRecordErrors() {
+	suite.True(errors.Is(Record(suite.db, &Person{}, ActionUpdate), ErrPluginNotRegistered))
+
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.True(errors.Is(Record(suite.db, &Person{}, ""), ErrInvalidAction))
+	suite.True(errors.Is(Record(suite.db, &Person{}, ActionUpdate), ErrUnsupportedOperation))
+}
+
+func (suite *PluginTestSuite) TestRecordRequiresReason() {
+	suite.Require().NoError(suite.db.Use(New(WithRequiredReason(&Person{}))))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(Disable(suite.db).Create(&p).Error)
+
+	err := Record(suite.db, &p, ActionCreate)
+	suite.True(errors.Is(err, ErrReasonRequired))
+	suite.assertHistoryCount(p.ID, 0)
+
+	suite.Require().NoError(Record(SetReason(suite.db, "import"), &p, ActionCreate))
+	suite.assertHistoryCount(p.ID, 1)
+}
+
+func (suite *PluginTestSuite) TestRecordExpectVersion() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	current, err := LatestVersion(suite.db, &p, p.ID)
+	suite.Require().NoError(err)
+
+	err = Record(ExpectVersion(suite.db, "stale"), &p, ActionUpdate)
+	suite.True(errors.Is(err, ErrVersionConflict))
+	suite.assertHistoryCount(p.ID, 2)
+
+	suite.Require().NoError(Record(ExpectVersion(suite.db, current), &p, ActionUpdate))
+	suite.assertHistoryCount(p.ID, 3)
+}
@@ -0,0 +1,289 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// tagName is the key of the tags mapping the fields of the history models which do not embed Entry.
+	tagName = "gorm-history"
+	// modelTagName is the key of the tags configuring the history of a model.
+	modelTagName = "gorm-history-model"
+)
+
+type (
+	// ModelConfig is the configuration of the history of one model, see Plugin.Register.
+	ModelConfig struct {
+		// Actions the history is recorded for. All the actions are recorded when it is empty.
+		Actions []Action
+		// IgnoredFields are not copied to the history model.
+		IgnoredFields []string
+		CopyFunc      CopyFunc
+		VersionFunc   VersionFunc
+		Store         Store
+		Disabled      bool
+	}
+
+	ModelOption func(c *ModelConfig)
+
+	// Store saves history entries, by default in the history table of the model through the db of the statement
+	// being recorded.
+	Store interface {
+		SaveHistory(db *gorm.DB, hs ...History) error
+	}
+
+	StoreFunc func(db *gorm.DB, hs ...History) error
+
+	dbStore struct {
+		db *gorm.DB
+	}
+
+	modelRegistry struct {
+		mu      sync.RWMutex
+		configs map[reflect.Type]*ModelConfig
+		stores  map[reflect.Type]Store
+	}
+
+	modelRegistration struct {
+		model Recordable
+		opts  []ModelOption
+	}
+)
+
+// WithModel registers the configuration of model when the plugin is created, see Plugin.Register.
+func WithModel(model Recordable, opts ...ModelOption) ConfigFunc {
+	return func(c *Config) {
+		c.models = append(c.models, modelRegistration{model: model, opts: opts})
+	}
+}
+
+func WithModelActions(actions ...Action) ModelOption {
+	return func(c *ModelConfig) {
+		c.Actions = append(c.Actions, actions...)
+	}
+}
+
+func WithIgnoredFields(names ...string) ModelOption {
+	return func(c *ModelConfig) {
+		c.IgnoredFields = append(c.IgnoredFields, names...)
+	}
+}
+
+func WithModelCopyFunc(fn CopyFunc) ModelOption {
+	return func(c *ModelConfig) {
+		c.CopyFunc = fn
+	}
+}
+
+func WithModelVersionFunc(fn VersionFunc) ModelOption {
+	return func(c *ModelConfig) {
+		c.VersionFunc = fn
+	}
+}
+
+func WithStore(store Store) ModelOption {
+	return func(c *ModelConfig) {
+		c.Store = store
+	}
+}
+
+// WithModelDisabled stops the history of the model from being recorded.
+func WithModelDisabled() ModelOption {
+	return func(c *ModelConfig) {
+		c.Disabled = true
+	}
+}
+
+// NewDBStore returns a store saving the history entries through db, e.g. in another database. The entries are
+// saved outside of the transaction of the statement being recorded.
+func NewDBStore(db *gorm.DB) Store {
+	return &dbStore{db: db}
+}
+
+// Register configures the history of model, overriding the configuration read from its struct tags. Models
+// which are not registered are configured from their struct tags, e.g.
+//
+//	type Person struct {
+//		_ struct{} `gorm-history-model:"actions:create,update"`
+//
+//		gorm.Model
+//		Password string `gorm-history-model:"-"`
+//	}
+//
+// where a gorm-history-model:"disabled" tag on the blank field turns the history of the model off.
+func (p *Plugin) Register(model Recordable, opts ...ModelOption) error {
+	c := &ModelConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for _, action := range c.Actions {
+		if err := action.Validate(); err != nil {
+			return err
+		}
+	}
+
+	t, err := modelType(model)
+	if err != nil {
+		return err
+	}
+
+	p.models.mu.Lock()
+	defer p.models.mu.Unlock()
+
+	p.models.configs[t] = c
+	if c.Store != nil {
+		p.models.stores[reflect.Indirect(reflect.ValueOf(historyModel(model.CreateHistory()))).Type()] = c.Store
+	}
+
+	return nil
+}
+
+// ModelConfig returns the configuration of the history of model.
+func (p *Plugin) ModelConfig(model Recordable) (*ModelConfig, error) {
+	t, err := modelType(model)
+	if err != nil {
+		return nil, err
+	}
+
+	p.models.mu.RLock()
+	c, ok := p.models.configs[t]
+	p.models.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	c, err = parseModelTags(t)
+	if err != nil {
+		return nil, err
+	}
+
+	p.models.mu.Lock()
+	defer p.models.mu.Unlock()
+
+	if registered, ok := p.models.configs[t]; ok {
+		return registered, nil
+	}
+
+	p.models.configs[t] = c
+
+	return c, nil
+}
+
+// Records reports whether the history of the model is recorded for action.
+func (c *ModelConfig) Records(action Action) bool {
+	if c.Disabled {
+		return false
+	}
+
+	if len(c.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range c.Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (fn StoreFunc) SaveHistory(db *gorm.DB, hs ...History) error {
+	return fn(db, hs...)
+}
+
+func (s *dbStore) SaveHistory(db *gorm.DB, hs ...History) error {
+	return saveHistory(s.db.WithContext(db.Statement.Context), hs...)
+}
+
+func newModelRegistry() *modelRegistry {
+	return &modelRegistry{
+		configs: make(map[reflect.Type]*ModelConfig),
+		stores:  make(map[reflect.Type]Store),
+	}
+}
+
+func (r *modelRegistry) store(h History) Store {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.stores[reflect.Indirect(reflect.ValueOf(historyModel(h))).Type()]
+}
+
+func saveHistory(db *gorm.DB, hs ...History) error {
+	db = db.Session(&gorm.Session{
+		NewDB: true,
+	})
+	for _, h := range hs {
+		if err := db.Omit(clause.Associations).Create(historyModel(h)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func modelType(model Recordable) (reflect.Type, error) {
+	if model == nil {
+		return nil, fmt.Errorf("recordable model is not set")
+	}
+
+	t := reflect.Indirect(reflect.ValueOf(model)).Type()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("recordable model %T is not a struct", model)
+	}
+
+	return t, nil
+}
+
+func parseModelTags(t reflect.Type) (*ModelConfig, error) {
+	c := &ModelConfig{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup(modelTagName)
+		if !ok {
+			continue
+		}
+
+		if field.Name != "_" {
+			if tag == "-" {
+				c.IgnoredFields = append(c.IgnoredFields, field.Name)
+			}
+
+			continue
+		}
+
+		for _, option := range strings.Split(tag, ";") {
+			kv := strings.SplitN(strings.TrimSpace(option), ":", 2)
+			switch strings.ToLower(kv[0]) {
+			case "":
+			case "disabled":
+				c.Disabled = true
+			case "actions":
+				if len(kv) < 2 {
+					return nil, fmt.Errorf("%s: actions tag option of %s has no value", modelTagName, t)
+				}
+
+				for _, action := range strings.Split(kv[1], ",") {
+					action := Action(strings.TrimSpace(action))
+					if err := action.Validate(); err != nil {
+						return nil, fmt.Errorf("%s: %w", modelTagName, err)
+					}
+
+					c.Actions = append(c.Actions, action)
+				}
+			default:
+				return nil, fmt.Errorf("%s: unknown tag option %q of %s", modelTagName, kv[0], t)
+			}
+		}
+	}
+
+	return c, nil
+}
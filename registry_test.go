@@ -0,0 +1,133 @@
+package history
+
+import "gorm.io/gorm"
+
+type (
+	Account struct {
+		_ struct{} `gorm-history-model:"actions:update"`
+
+		gorm.Model
+		Name   string
+		Secret string `gorm-history-model:"-"`
+	}
+
+	AccountHistory struct {
+		gorm.Model
+		Entry
+
+		Name   string
+		Secret string
+	}
+)
+
+func (Account) CreateHistory() History {
+	return &AccountHistory{}
+}
+
+func (suite *PluginTestSuite) TestRegisterActions() {
+	plugin := New()
+	suite.Require().NoError(suite.db.Use(plugin))
+	suite.Require().NoError(plugin.Register(&Person{}, WithModelActions(ActionUpdate), WithIgnoredFields("LastName")))
+
+	p := Person{FirstName: "John", LastName: "Doe"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	a := Address{Line1: "Line 1"}
+	suite.Require().NoError(suite.db.Create(&a).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Find(&entries, "object_id = ?", p.ID).Error)
+	suite.Require().Len(entries, 1)
+	suite.Equal(ActionUpdate, entries[0].Action)
+	suite.Equal("Jane", entries[0].FirstName)
+	suite.Zero(entries[0].LastName)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&AddressHistory{}).Where("object_id = ?", a.ID).Count(&count).Error)
+	suite.EqualValues(1, count)
+}
+
+func (suite *PluginTestSuite) TestRegisterFuncsAndStore() {
+	var stored []History
+	store := StoreFunc(func(db *gorm.DB, hs ...History) error {
+		stored = append(stored, hs...)
+
+		return nil
+	})
+	version := func(ctx *Context) (Version, error) {
+		return "v1", nil
+	}
+
+	suite.Require().NoError(suite.db.Use(New(
+		WithModel(&Person{}, WithModelVersionFunc(version), WithStore(store)),
+		WithModel(&Address{}, WithModelDisabled()),
+	)))
+
+	p := Person{FirstName: "John", Address: &Address{Line1: "Line 1"}}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	suite.Require().Len(stored, 1)
+	ph, ok := stored[0].(*PersonHistory)
+	suite.Require().True(ok)
+	suite.Equal(Version("v1"), ph.Version)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Count(&count).Error)
+	suite.Zero(count)
+
+	suite.Require().NoError(suite.db.Model(&AddressHistory{}).Count(&count).Error)
+	suite.Zero(count)
+}
+
+func (suite *PluginTestSuite) TestRegisterInvalidAction() {
+	plugin := New()
+	suite.Require().NoError(suite.db.Use(plugin))
+	suite.Error(plugin.Register(&Person{}, WithModelActions("")))
+}
+
+func (suite *PluginTestSuite) TestModelTags() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&Account{}, &AccountHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&Account{}, &AccountHistory{}))
+	}()
+
+	a := Account{Name: "John", Secret: "secret"}
+	suite.Require().NoError(suite.db.Create(&a).Error)
+
+	a.Name = "Jane"
+	suite.Require().NoError(suite.db.Save(&a).Error)
+
+	var entries []AccountHistory
+	suite.Require().NoError(suite.db.Find(&entries, "object_id = ?", a.ID).Error)
+	suite.Require().Len(entries, 1)
+	suite.Equal(ActionUpdate, entries[0].Action)
+	suite.Equal("Jane", entries[0].Name)
+	suite.Zero(entries[0].Secret)
+}
+
+func (suite *PluginTestSuite) TestNotRecordedBulkUpdate() {
+	plugin := New(WithModel(&Address{}, WithModelDisabled()))
+	suite.Require().NoError(suite.db.Use(plugin))
+	suite.Require().NoError(plugin.Register(&Person{}, WithModelActions(ActionCreate)))
+
+	p := Person{FirstName: "John"}
+	a := Address{Line1: "Line 1"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+	suite.Require().NoError(suite.db.Create(&a).Error)
+
+	err := suite.db.Model(&Person{}).Where("first_name = ?", "John").Update("last_name", "Doe").Error
+	suite.Require().NoError(err)
+
+	err = suite.db.Model(&Address{}).Where("line1 = ?", "Line 1").Update("city", "Paris").Error
+	suite.Require().NoError(err)
+
+	suite.assertHistoryCount(p.ID, 1)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&AddressHistory{}).Count(&count).Error)
+	suite.Zero(count)
+}
@@ -0,0 +1,253 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const defaultPruneBatchSize = 1000
+
+var ErrPluginNotRegistered = errors.New("history plugin is not registered")
+
+type (
+	// RetentionPolicy describes which history entries of Model are kept. An entry is pruned only when it
+	// breaks every limit that is set: it is older than MaxAge and it is not one of the last MaxVersions
+	// entries of its object. Entries having one of the KeepActions are never pruned.
+	RetentionPolicy struct {
+		Model       Recordable
+		MaxAge      time.Duration
+		MaxVersions int
+		KeepActions []Action
+	}
+
+	// PruneResult reports what a Prune or Compact run did to the entries of Table.
+	PruneResult struct {
+		Table    string
+		Archived int64
+		Deleted  int64
+		Batches  int
+	}
+)
+
+func WithRetentionPolicy(policies ...RetentionPolicy) ConfigFunc {
+	return func(c *Config) {
+		c.RetentionPolicies = append(c.RetentionPolicies, policies...)
+	}
+}
+
+func WithPruneBatchSize(size int) ConfigFunc {
+	return func(c *Config) {
+		c.PruneBatchSize = size
+	}
+}
+
+// Prune deletes the history entries which are not retained anymore by the retention policies the plugin
+// registered on db was configured with.
+func Prune(ctx context.Context, db *gorm.DB) ([]PruneResult, error) {
+	p, err := getPlugin(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Prune(ctx, db)
+}
+
+func (p *Plugin) Prune(ctx context.Context, db *gorm.DB) (results []PruneResult, err error) {
+	db = db.Session(&gorm.Session{
+		NewDB:   true,
+		Context: ctx,
+	})
+
+	now := db.NowFunc()
+	var archive *archiveRun
+	if p.archiveStorage != nil {
+		if archive, err = p.newArchiveRun(now); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			if cerr := archive.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("error archiving history: %w", cerr)
+			}
+		}()
+	}
+
+	results = make([]PruneResult, 0, len(p.retentionPolicies))
+	for _, policy := range p.retentionPolicies {
+		s, err := parseHistorySchema(db, policy.Model)
+		if err != nil {
+			return results, err
+		}
+
+		result := PruneResult{Table: s.Table}
+		err = p.prune(db, archive, s, policy, now, &result)
+		results = append(results, result)
+
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// prune archives and deletes the entries of s which are not retained by policy anymore, one batch at a time, so that
+// at most PruneBatchSize rows are held in memory.
+func (p *Plugin) prune(db *gorm.DB, archive *archiveRun, s *schema.Schema, policy RetentionPolicy, now time.Time, result *PruneResult) error {
+	var after interface{}
+	for {
+		ids, err := p.findPrunable(db, s, policy, now, after)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		archived, deleted, err := p.deleteBatch(db, archive, s, ids)
+		result.Archived += archived
+		result.Deleted += deleted
+
+		if err != nil {
+			return err
+		}
+
+		result.Batches++
+
+		if len(ids) < p.pruneBatchSize {
+			return nil
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+// deleteBatch deletes the entries of s having ids, once they were written to archive when archiving is configured.
+// It returns the number of entries archived and deleted.
+func (p *Plugin) deleteBatch(db *gorm.DB, archive *archiveRun, s *schema.Schema, ids []interface{}) (int64, int64, error) {
+	var archived int64
+	if archive != nil {
+		n, err := archive.Write(db, s, ids)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error archiving history: %w", err)
+		}
+
+		archived = n
+	}
+
+	deleted, err := deleteHistory(db, s, ids)
+
+	return archived, deleted, err
+}
+
+// findPrunable returns the next batch of primary keys, greater than after when it is set, of the entries of s which
+// are not retained by policy anymore.
+func (p *Plugin) findPrunable(db *gorm.DB, s *schema.Schema, policy RetentionPolicy, now time.Time, after interface{}) ([]interface{}, error) {
+	if policy.MaxAge <= 0 && policy.MaxVersions <= 0 {
+		return nil, nil
+	}
+
+	pk := s.PrioritizedPrimaryField
+	q := db.
+		Unscoped().
+		Model(reflect.New(s.ModelType).Interface()).
+		Order(qualifiedColumn(db, s.Table, pk.DBName)).
+		Limit(p.pruneBatchSize)
+
+	if after != nil {
+		q = q.Where(fmt.Sprintf("%s > ?", qualifiedColumn(db, s.Table, pk.DBName)), after)
+	}
+
+	if len(policy.KeepActions) > 0 {
+		col, err := lookUpColumn(s, "Action")
+		if err != nil {
+			return nil, err
+		}
+
+		q = q.Where(fmt.Sprintf("%s NOT IN ?", db.Statement.Quote(col)), policy.KeepActions)
+	}
+
+	if policy.MaxAge > 0 {
+		col, err := lookUpColumn(s, "CreatedAt")
+		if err != nil {
+			return nil, err
+		}
+
+		q = q.Where(fmt.Sprintf("%s < ?", db.Statement.Quote(col)), now.Add(-policy.MaxAge))
+	}
+
+	if policy.MaxVersions > 0 {
+		objectID, err := lookUpColumn(s, "ObjectID")
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := lookUpColumn(s, "Version")
+		if err != nil {
+			return nil, err
+		}
+
+		newer := fmt.Sprintf(
+			"(SELECT COUNT(*) FROM %[1]s AS newer WHERE newer.%[2]s = %[1]s.%[2]s AND "+
+				"(newer.%[3]s > %[1]s.%[3]s OR (newer.%[3]s = %[1]s.%[3]s AND newer.%[4]s > %[1]s.%[4]s))) >= ?",
+			db.Statement.Quote(s.Table),
+			db.Statement.Quote(objectID),
+			db.Statement.Quote(version),
+			db.Statement.Quote(pk.DBName),
+		)
+		q = q.Where(newer, policy.MaxVersions)
+	}
+
+	var ids []interface{}
+	if err := q.Pluck(pk.DBName, &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func deleteHistory(db *gorm.DB, s *schema.Schema, ids []interface{}) (int64, error) {
+	tx := db.
+		Unscoped().
+		Where(fmt.Sprintf("%s IN ?", db.Statement.Quote(s.PrioritizedPrimaryField.DBName)), ids).
+		Delete(reflect.New(s.ModelType).Interface())
+
+	return tx.RowsAffected, tx.Error
+}
+
+func qualifiedColumn(db *gorm.DB, table, column string) string {
+	return db.Statement.Quote(table) + "." + db.Statement.Quote(column)
+}
+
+func parseHistorySchema(db *gorm.DB, r Recordable) (*schema.Schema, error) {
+	if r == nil {
+		return nil, errors.New("recordable model is not set")
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(historyModel(r.CreateHistory())); err != nil {
+		return nil, err
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("history model of %T does not have a primary key", r)
+	}
+
+	return stmt.Schema, nil
+}
+
+func getPlugin(db *gorm.DB) (*Plugin, error) {
+	p, ok := db.Config.Plugins[pluginName].(*Plugin)
+	if !ok {
+		return nil, ErrPluginNotRegistered
+	}
+
+	return p, nil
+}
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (suite *PluginTestSuite) TestPruneMaxAge() {
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:  &Person{},
+			MaxAge: time.Hour,
+		}),
+		WithPruneBatchSize(2),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(4)
+
+	old := suite.db.NowFunc().Add(-2 * time.Hour)
+	err := suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ? AND action = ?", p.ID, ActionCreate).
+		Update("created_at", old).
+		Error
+	suite.Require().NoError(err)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Require().Len(results, 1)
+	suite.Equal("person_histories", results[0].Table)
+	suite.EqualValues(1, results[0].Deleted)
+
+	suite.assertHistoryCount(p.ID, 4)
+}
+
+func (suite *PluginTestSuite) TestPruneMaxVersions() {
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 2,
+		}),
+		WithPruneBatchSize(2),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(5)
+	other := suite.createPersonWithUpdates(1)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Require().Len(results, 1)
+	suite.EqualValues(4, results[0].Deleted)
+	suite.Equal(2, results[0].Batches)
+
+	suite.assertHistoryCount(p.ID, 2)
+	suite.assertHistoryCount(other.ID, 2)
+
+	var entries []PersonHistory
+	err = suite.db.Order("version asc").Find(&entries, "object_id = ?", p.ID).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+	suite.Equal("Jane 4", entries[0].FirstName)
+	suite.Equal("Jane 5", entries[1].FirstName)
+}
+
+func (suite *PluginTestSuite) TestPruneKeepActions() {
+	plugin := New(
+		WithRetentionPolicy(RetentionPolicy{
+			Model:       &Person{},
+			MaxVersions: 1,
+			KeepActions: []Action{ActionCreate},
+		}),
+	)
+	suite.Require().NoError(suite.db.Use(plugin))
+
+	p := suite.createPersonWithUpdates(3)
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.EqualValues(2, results[0].Deleted)
+
+	var actions []Action
+	err = suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", p.ID).
+		Order("version asc").
+		Pluck("action", &actions).
+		Error
+	suite.Require().NoError(err)
+	suite.Equal([]Action{ActionCreate, ActionUpdate}, actions)
+}
+
+func (suite *PluginTestSuite) TestPruneWithoutPlugin() {
+	_, err := Prune(context.Background(), suite.db)
+	suite.True(errors.Is(err, ErrPluginNotRegistered))
+}
+
+func (suite *PluginTestSuite) createPersonWithUpdates(n int) *Person {
+	p := Person{
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	for i := 1; i <= n; i++ {
+		p.FirstName = fmt.Sprintf("Jane %d", i)
+		suite.Require().NoError(suite.db.Save(&p).Error)
+	}
+
+	return &p
+}
+
+func (suite *PluginTestSuite) assertHistoryCount(objectID uint, expected int64) {
+	var count int64
+	err := suite.db.
+		Session(&gorm.Session{NewDB: true}).
+		Model(&PersonHistory{}).
+		Where("object_id = ?", objectID).
+		Count(&count).
+		Error
+	suite.Require().NoError(err)
+	suite.Equal(expected, count)
+}
@@ -0,0 +1,227 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+const (
+	TagVersion    = "version"
+	TagObjectID   = "objectID"
+	TagAction     = "action"
+	TagUser       = "user"
+	TagEmail      = "email"
+	TagSourceID   = "sourceID"
+	TagSourceType = "sourceType"
+	TagCreatedAt  = "createdAt"
+)
+
+// fieldTags maps the fields of Entry to the gorm-history tags of the models wrapped by TaggedHistory.
+var fieldTags = map[string]string{
+	"Version":    TagVersion,
+	"ObjectID":   TagObjectID,
+	"Action":     TagAction,
+	"UserID":     TagUser,
+	"UserEmail":  TagEmail,
+	"SourceID":   TagSourceID,
+	"SourceType": TagSourceType,
+	"CreatedAt":  TagCreatedAt,
+}
+
+var (
+	_ History              = (*TaggedHistory)(nil)
+	_ TimestampableHistory = (*TaggedHistory)(nil)
+	_ BlameableHistory     = (*TaggedHistory)(nil)
+	_ SourceableHistory    = (*TaggedHistory)(nil)
+)
+
+type (
+	actionSetter interface {
+		SetHistoryAction(action Action)
+	}
+
+	versionSetter interface {
+		SetHistoryVersion(version Version)
+	}
+
+	objectIDSetter interface {
+		SetHistoryObjectID(id interface{})
+	}
+)
+
+// taggedSchemas caches the schemas of the tagged history models, whose fields are only looked up by tag.
+var taggedSchemas = &sync.Map{}
+
+// TaggedHistory is a History wrapping a history model which doesn't implement it. The model either implements the
+// setters of the values it records or carries gorm-history tags on the fields holding them: the version, the object
+// ID and the action (and optionally the user, email, sourceID, sourceType and createdAt), e.g.
+//
+//	type NoteHistory struct {
+//		ID uint `gorm:"primaryKey"`
+//
+//		Rev    history.Version `gorm-history:"version"`
+//		NoteID uint            `gorm:"index" gorm-history:"objectID"`
+//		Kind   history.Action  `gorm:"type:varchar(24)" gorm-history:"action"`
+//	}
+//
+//	func (Note) CreateHistory() history.History {
+//		return history.NewTaggedHistory(&NoteHistory{})
+//	}
+type TaggedHistory struct {
+	model interface{}
+	err   error
+}
+
+func NewTaggedHistory(model interface{}) *TaggedHistory {
+	return &TaggedHistory{model: model}
+}
+
+// Model returns the wrapped history model.
+func (h *TaggedHistory) Model() interface{} {
+	return h.model
+}
+
+func (h *TaggedHistory) SetHistoryVersion(version Version) {
+	if m, ok := h.model.(versionSetter); ok {
+		m.SetHistoryVersion(version)
+	} else {
+		h.set(TagVersion, version, true)
+	}
+}
+
+func (h *TaggedHistory) SetHistoryObjectID(id interface{}) {
+	if m, ok := h.model.(objectIDSetter); ok {
+		m.SetHistoryObjectID(id)
+	} else {
+		h.set(TagObjectID, id, true)
+	}
+}
+
+func (h *TaggedHistory) SetHistoryAction(action Action) {
+	if m, ok := h.model.(actionSetter); ok {
+		m.SetHistoryAction(action)
+	} else {
+		h.set(TagAction, action, true)
+	}
+}
+
+func (h *TaggedHistory) SetHistoryCreatedAt(createdAt time.Time) {
+	if m, ok := h.model.(TimestampableHistory); ok {
+		m.SetHistoryCreatedAt(createdAt)
+	} else {
+		h.set(TagCreatedAt, createdAt, false)
+	}
+}
+
+func (h *TaggedHistory) SetHistoryUserID(id string) {
+	if m, ok := h.model.(BlameableHistory); ok {
+		m.SetHistoryUserID(id)
+	} else {
+		h.set(TagUser, id, false)
+	}
+}
+
+func (h *TaggedHistory) SetHistoryUserEmail(email string) {
+	if m, ok := h.model.(BlameableHistory); ok {
+		m.SetHistoryUserEmail(email)
+	} else {
+		h.set(TagEmail, email, false)
+	}
+}
+
+func (h *TaggedHistory) SetHistorySourceID(id string) {
+	if m, ok := h.model.(SourceableHistory); ok {
+		m.SetHistorySourceID(id)
+	} else {
+		h.set(TagSourceID, id, false)
+	}
+}
+
+func (h *TaggedHistory) SetHistorySourceType(typ string) {
+	if m, ok := h.model.(SourceableHistory); ok {
+		m.SetHistorySourceType(typ)
+	} else {
+		h.set(TagSourceType, typ, false)
+	}
+}
+
+// set sets the field of the model tagged with tag, keeping the first error, which the plugin returns once the
+// history is built.
+func (h *TaggedHistory) set(tag string, value interface{}, required bool) {
+	if h.err != nil {
+		return
+	}
+
+	ok, err := setTaggedField(h.model, tag, value)
+	if err != nil {
+		h.err = err
+
+		return
+	}
+
+	if !ok && required {
+		h.err = fmt.Errorf("history model %T does not have a %s:%q field", h.model, tagName, tag)
+	}
+}
+
+// historyModel returns the model h is saved as, which is the model wrapped by h if h is a TaggedHistory.
+func historyModel(h History) interface{} {
+	if th, ok := h.(*TaggedHistory); ok {
+		return th.model
+	}
+
+	return h
+}
+
+// historyEntry returns the History of row, a model loaded from the history table of the models whose history
+// model is like h.
+func historyEntry(h History, row interface{}) History {
+	if _, ok := h.(*TaggedHistory); ok {
+		return NewTaggedHistory(row)
+	}
+
+	return row.(History)
+}
+
+func setTaggedField(model interface{}, tag string, value interface{}) (bool, error) {
+	rv := reflect.ValueOf(model)
+	if rv.Kind() != reflect.Ptr {
+		return false, fmt.Errorf("history model %T must be a pointer", model)
+	}
+
+	s, err := schema.Parse(model, taggedSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return false, err
+	}
+
+	field := taggedField(s, tag)
+	if field == nil {
+		return false, nil
+	}
+
+	if field.FieldType.Kind() == reflect.String {
+		value = fmt.Sprintf("%v", value)
+	}
+
+	if err := field.Set(context.Background(), rv, value); err != nil {
+		return false, fmt.Errorf("error setting %s:%q field of %T: %w", tagName, tag, model, err)
+	}
+
+	return true, nil
+}
+
+func taggedField(s *schema.Schema, tag string) *schema.Field {
+	for _, field := range s.Fields {
+		if strings.EqualFold(strings.TrimSpace(field.Tag.Get(tagName)), tag) {
+			return field
+		}
+	}
+
+	return nil
+}
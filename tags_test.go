@@ -0,0 +1,81 @@
+package history
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	Note struct {
+		gorm.Model
+
+		Text string
+	}
+
+	NoteHistory struct {
+		ID uint `gorm:"primaryKey"`
+
+		Text       string
+		Rev        Version   `gorm:"type:char(26)" gorm-history:"version"`
+		NoteID     uint      `gorm:"index" gorm-history:"objectID"`
+		Kind       Action    `gorm:"type:varchar(24)" gorm-history:"action"`
+		Author     string    `gorm-history:"user"`
+		Mail       string    `gorm-history:"email"`
+		Origin     string    `gorm-history:"sourceID"`
+		OriginType string    `gorm-history:"sourceType"`
+		RecordedAt time.Time `gorm-history:"createdAt"`
+	}
+)
+
+func (Note) CreateHistory() History {
+	return NewTaggedHistory(&NoteHistory{})
+}
+
+func (suite *PluginTestSuite) TestTaggedHistoryModel() {
+	suite.Require().NoError(suite.db.Use(New()))
+	suite.Require().NoError(suite.db.AutoMigrate(&Note{}, &NoteHistory{}))
+	defer func() {
+		suite.Require().NoError(suite.db.Migrator().DropTable(&Note{}, &NoteHistory{}))
+	}()
+
+	db := SetUser(suite.db, User{ID: "123", Email: "john@doe.com"})
+	db = SetSource(db, Source{ID: "job-1", Type: "cron"})
+
+	n := Note{Text: "first"}
+	suite.Require().NoError(db.Create(&n).Error)
+
+	n.Text = "second"
+	suite.Require().NoError(db.Save(&n).Error)
+
+	var entries []NoteHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "note_id = ?", n.ID).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(ActionCreate, entries[0].Kind)
+	suite.Equal(ActionUpdate, entries[1].Kind)
+	suite.NotZero(entries[1].Rev)
+	suite.Equal("second", entries[1].Text)
+	suite.Equal("123", entries[1].Author)
+	suite.Equal("john@doe.com", entries[1].Mail)
+	suite.Equal("job-1", entries[1].Origin)
+	suite.Equal("cron", entries[1].OriginType)
+	suite.NotZero(entries[1].RecordedAt)
+
+	latest, err := LatestVersion(suite.db, &n, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(entries[1].Rev, latest)
+}
+
+func (suite *PluginTestSuite) TestUntaggedHistoryModel() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	type untagged struct {
+		ID   uint
+		Text string
+	}
+
+	h := NewTaggedHistory(&untagged{})
+	h.SetHistoryVersion("")
+	h.SetHistoryObjectID(1)
+	suite.Error(h.err)
+}
@@ -0,0 +1,357 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+type (
+	// triggerColumn is a column of a history table and the SQL expression of its value in the trigger body.
+	triggerColumn struct {
+		name string
+		expr string
+	}
+
+	triggerDialect struct {
+		name string
+	}
+)
+
+// TriggerDDL returns the statements creating the AFTER INSERT and AFTER UPDATE triggers of the table of model, for
+// the sqlite, postgres or mysql dialect, which copy the inserted and updated rows to the history table of model the
+// same way the plugin does. This captures the writes which bypass gorm, e.g. the ones of other services. The
+// triggers leave the version empty, like ULIDVersion does for create entries by default: run BackfillVersions to
+// version the entries they write. The user, source, reason and metadata of the changes are not known to the
+// database, so they are left empty. Don't register the plugin for the models having triggers, or their changes are
+// recorded twice. The actions the model config doesn't record get statements dropping their triggers instead, and
+// the soft deletes of models with a gorm.DeletedAt field are not recorded, like the plugin does.
+func TriggerDDL(db *gorm.DB, dialect string, model Recordable) ([]string, error) {
+	d := triggerDialect{name: dialect}
+	switch dialect {
+	case DialectSQLite, DialectPostgres, DialectMySQL:
+	default:
+		return nil, fmt.Errorf("triggers are not supported for %q dialect: %w", dialect, ErrUnsupportedOperation)
+	}
+
+	db = db.Session(&gorm.Session{NewDB: true})
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	hs, err := parseHistorySchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hs.PrioritizedPrimaryField.AutoIncrement && !hs.PrioritizedPrimaryField.HasDefaultValue {
+		return nil, fmt.Errorf("history model of %T must have an auto increment primary key: %w", model, ErrUnsupportedOperation)
+	}
+
+	cfg, err := modelConfig(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	var ddl []string
+	for _, action := range []Action{ActionCreate, ActionUpdate} {
+		if !cfg.Records(action) {
+			ddl = append(ddl, d.dropTrigger(stmt.Schema.Table, action)...)
+
+			continue
+		}
+
+		columns, err := triggerColumns(d, stmt.Schema, hs, cfg, action)
+		if err != nil {
+			return nil, err
+		}
+
+		var when string
+		if field := softDeleteField(stmt.Schema); field != nil && action == ActionUpdate {
+			col := d.quote(field.DBName)
+			when = fmt.Sprintf("NEW.%s IS NULL OR OLD.%s IS NOT NULL", col, col)
+		}
+
+		ddl = append(ddl, d.trigger(stmt.Schema.Table, hs.Table, action, columns, when)...)
+	}
+
+	return ddl, nil
+}
+
+// CreateTriggers creates the triggers of models returned by TriggerDDL for the dialect of db.
+func CreateTriggers(db *gorm.DB, models ...Recordable) error {
+	for _, model := range models {
+		ddl, err := TriggerDDL(db, db.Dialector.Name(), model)
+		if err != nil {
+			return err
+		}
+
+		for _, sql := range ddl {
+			if err := db.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func modelConfig(db *gorm.DB, model Recordable) (*ModelConfig, error) {
+	if p, err := getPlugin(db); err == nil {
+		return p.ModelConfig(model)
+	}
+
+	t, err := modelType(model)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseModelTags(t)
+}
+
+// softDeleteField returns the gorm.DeletedAt field of s, if any.
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			return field
+		}
+	}
+
+	return nil
+}
+
+func triggerColumns(d triggerDialect, s, hs *schema.Schema, cfg *ModelConfig, action Action) ([]triggerColumn, error) {
+	objectIDCol, err := lookUpColumn(hs, "ObjectID")
+	if err != nil {
+		return nil, err
+	}
+
+	entryColumns := make(map[string]string)
+	for name := range fieldTags {
+		if col, err := lookUpColumn(hs, name); err == nil {
+			entryColumns[col] = name
+		}
+	}
+
+	ignored := make(map[string]bool)
+	for _, name := range cfg.IgnoredFields {
+		if field := s.LookUpField(name); field != nil {
+			ignored[field.DBName] = true
+		}
+	}
+
+	var columns []triggerColumn
+	for _, field := range hs.Fields {
+		if field.DBName == "" || hs.FieldsByDBName[field.DBName] != field || field.PrimaryKey && field == hs.PrioritizedPrimaryField {
+			continue
+		}
+
+		var expr string
+		switch entryColumns[field.DBName] {
+		case "ObjectID":
+			expr = d.objectID(s, field)
+		case "Action":
+			expr = d.quoteString(string(action))
+		case "CreatedAt":
+			expr = d.now()
+		default:
+			modelField := s.LookUpField(field.DBName)
+			if field.DBName != objectIDCol && modelField != nil && !modelField.PrimaryKey && modelField.DBName != "" && !ignored[modelField.DBName] {
+				expr = "NEW." + d.quote(modelField.DBName)
+			} else {
+				expr = d.zero(field)
+			}
+		}
+
+		if expr != "" {
+			columns = append(columns, triggerColumn{name: field.DBName, expr: expr})
+		}
+	}
+
+	return columns, nil
+}
+
+// trigger returns the statements creating the trigger of action on table, which inserts columns into historyTable
+// for the rows matching the when condition, or for every row when it is empty.
+func (d triggerDialect) trigger(table, historyTable string, action Action, columns []triggerColumn, when string) []string {
+	name := triggerName(table, action)
+	event := "INSERT"
+	if action == ActionUpdate {
+		event = "UPDATE"
+	}
+
+	names := make([]string, len(columns))
+	exprs := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = d.quote(c.name)
+		exprs[i] = c.expr
+	}
+
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		d.quote(historyTable),
+		strings.Join(names, ", "),
+		strings.Join(exprs, ", "),
+	)
+
+	switch d.name {
+	case DialectPostgres:
+		var condition string
+		if when != "" {
+			condition = fmt.Sprintf(" WHEN (%s)", when)
+		}
+
+		return []string{
+			fmt.Sprintf("CREATE OR REPLACE FUNCTION %s() RETURNS TRIGGER AS $$ BEGIN %s; RETURN NEW; END; $$ LANGUAGE plpgsql", d.quote(name), insert),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", d.quote(name), d.quote(table)),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER %s ON %s FOR EACH ROW%s EXECUTE FUNCTION %s()", d.quote(name), event, d.quote(table), condition, d.quote(name)),
+		}
+	case DialectMySQL:
+		body := insert
+		if when != "" {
+			body = fmt.Sprintf("BEGIN IF %s THEN %s; END IF; END", when, insert)
+		}
+
+		return []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s", d.quote(name)),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER %s ON %s FOR EACH ROW %s", d.quote(name), event, d.quote(table), body),
+		}
+	default:
+		var condition string
+		if when != "" {
+			condition = fmt.Sprintf(" WHEN %s", when)
+		}
+
+		return []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s", d.quote(name)),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER %s ON %s FOR EACH ROW%s BEGIN %s; END", d.quote(name), event, d.quote(table), condition, insert),
+		}
+	}
+}
+
+// dropTrigger returns the statements dropping the trigger of action on table, if it exists.
+func (d triggerDialect) dropTrigger(table string, action Action) []string {
+	name := triggerName(table, action)
+	if d.name == DialectPostgres {
+		return []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", d.quote(name), d.quote(table)),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", d.quote(name)),
+		}
+	}
+
+	return []string{fmt.Sprintf("DROP TRIGGER IF EXISTS %s", d.quote(name))}
+}
+
+func triggerName(table string, action Action) string {
+	return fmt.Sprintf("%s_history_%s", table, action)
+}
+
+// objectID returns the expression of the object ID of the history entries: the primary key of the row, or the
+// JSON array of its parts for composite keys, formatted like CompositeKey.
+func (d triggerDialect) objectID(s *schema.Schema, field *schema.Field) string {
+	pks := s.PrimaryFields
+	if len(pks) == 1 {
+		expr := "NEW." + d.quote(pks[0].DBName)
+		if field.FieldType.Kind() == reflect.String && pks[0].FieldType.Kind() != reflect.String {
+			return d.castString(expr)
+		}
+
+		return expr
+	}
+
+	parts := []string{"'['"}
+	for i, pk := range pks {
+		if i > 0 {
+			parts = append(parts, "','")
+		}
+
+		parts = append(parts, d.jsonValue("NEW."+d.quote(pk.DBName), pk))
+	}
+
+	parts = append(parts, "']'")
+
+	return d.concat(parts)
+}
+
+func (d triggerDialect) jsonValue(expr string, field *schema.Field) string {
+	switch d.name {
+	case DialectPostgres:
+		return fmt.Sprintf("to_json(%s)::text", expr)
+	case DialectMySQL:
+		if field.FieldType.Kind() == reflect.String {
+			return fmt.Sprintf("JSON_QUOTE(%s)", expr)
+		}
+
+		return d.castString(expr)
+	default:
+		return fmt.Sprintf("json_quote(%s)", expr)
+	}
+}
+
+func (d triggerDialect) concat(parts []string) string {
+	if d.name == DialectMySQL {
+		return fmt.Sprintf("CONCAT(%s)", strings.Join(parts, ", "))
+	}
+
+	return strings.Join(parts, " || ")
+}
+
+func (d triggerDialect) castString(expr string) string {
+	if d.name == DialectMySQL {
+		return fmt.Sprintf("CAST(%s AS CHAR)", expr)
+	}
+
+	return fmt.Sprintf("CAST(%s AS TEXT)", expr)
+}
+
+// now returns the current time. SQLite stores times as text, compared as strings, so the SQLite triggers write it in
+// the local zone and the layout of the driver, like the times the plugin writes: "2006-01-02 15:04:05.999-07:00".
+func (d triggerDialect) now() string {
+	switch d.name {
+	case DialectPostgres, DialectMySQL:
+		return "CURRENT_TIMESTAMP"
+	default:
+		return "(SELECT strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || " +
+			"printf('%s%02d:%02d', CASE WHEN m < 0 THEN '-' ELSE '+' END, abs(m) / 60, abs(m) % 60) " +
+			"FROM (SELECT CAST(round((julianday('now', 'localtime') - julianday('now')) * 1440) AS INTEGER) AS m))"
+	}
+}
+
+// zero returns the zero value the plugin writes for field when it has nothing to copy to it, or an empty string
+// to leave the column to its default.
+func (d triggerDialect) zero(field *schema.Field) string {
+	switch field.FieldType.Kind() {
+	case reflect.String:
+		return "''"
+	case reflect.Bool:
+		return "FALSE"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "0"
+	}
+
+	return ""
+}
+
+func (d triggerDialect) quote(name string) string {
+	if d.name == DialectMySQL {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (d triggerDialect) quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (suite *PluginTestSuite) TestTriggers() {
+	suite.Require().NoError(CreateTriggers(suite.db, &Person{}))
+	defer func() {
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_create"`)
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_update"`)
+	}()
+
+	err := suite.db.Exec("INSERT INTO people (first_name, last_name) VALUES (?, ?)", "John", "Doe").Error
+	suite.Require().NoError(err)
+
+	var p Person
+	suite.Require().NoError(suite.db.First(&p, "first_name = ?", "John").Error)
+	suite.Require().NoError(suite.db.Exec("UPDATE people SET first_name = ? WHERE id = ?", "Jane", p.ID).Error)
+
+	var versions []Version
+	suite.Require().NoError(suite.db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Pluck("version", &versions).Error)
+	suite.Equal([]Version{"", ""}, versions, "the triggers leave the version empty until BackfillVersions runs")
+
+	suite.Require().NoError(suite.db.Exec(`DROP TRIGGER "people_history_create"`).Error)
+	suite.Require().NoError(suite.db.Exec(`DROP TRIGGER "people_history_update"`).Error)
+	suite.Require().NoError(suite.db.Use(New()))
+
+	suite.Require().NoError(suite.db.First(&p, p.ID).Error)
+	suite.Require().NoError(suite.db.Model(&p).Update("last_name", "Smith").Error)
+
+	n, err := BackfillVersions(suite.db, &Person{})
+	suite.Require().NoError(err)
+	suite.EqualValues(2, n)
+
+	entries, err := FindObjectHistory(suite.db, &Person{}, p.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 3)
+
+	expected := []struct {
+		action    Action
+		firstName string
+		lastName  string
+	}{
+		{ActionCreate, "John", "Doe"},
+		{ActionUpdate, "Jane", "Doe"},
+		{ActionUpdate, "Jane", "Smith"},
+	}
+	for i, e := range expected {
+		h := entries[i].(*PersonHistory)
+		suite.Equal(e.action, h.Action)
+		suite.Equal(e.firstName, h.FirstName)
+		suite.Equal(e.lastName, h.LastName)
+		suite.Equal(fmt.Sprint(p.ID), h.ObjectID)
+		suite.NotZero(h.Version)
+		suite.NotZero(h.Model.CreatedAt)
+	}
+}
+
+func (suite *PluginTestSuite) TestTriggerDDL() {
+	ddl, err := TriggerDDL(suite.db, DialectPostgres, &Person{})
+	suite.Require().NoError(err)
+	sql := strings.Join(ddl, ";\n")
+	suite.Contains(sql, `CREATE OR REPLACE FUNCTION "people_history_create"()`)
+	suite.Contains(sql, `AFTER UPDATE ON "people" FOR EACH ROW WHEN (NEW."deleted_at" IS NULL OR OLD."deleted_at" IS NOT NULL) EXECUTE FUNCTION "people_history_update"()`)
+	suite.Contains(sql, `CAST(NEW."id" AS TEXT)`)
+	suite.Equal(1, strings.Count(sql[:strings.Index(sql, ";")], `"created_at"`))
+
+	ddl, err = TriggerDDL(suite.db, DialectMySQL, &OrderLine{})
+	suite.Require().NoError(err)
+	sql = strings.Join(ddl, ";\n")
+	suite.Contains(sql, "CREATE TRIGGER `order_lines_history_create` AFTER INSERT ON `order_lines`")
+	suite.Contains(sql, "AFTER UPDATE ON `order_lines` FOR EACH ROW INSERT INTO")
+	suite.Contains(sql, "CONCAT('[', CAST(NEW.`order_id` AS CHAR), ',', CAST(NEW.`line_no` AS CHAR), ']')")
+
+	ddl, err = TriggerDDL(suite.db, DialectMySQL, &TenantDocument{})
+	suite.Require().NoError(err)
+	sql = strings.Join(ddl, ";\n")
+	suite.Contains(sql, "CONCAT('[', CAST(NEW.`id` AS CHAR), ',', CAST(NEW.`tenant_id` AS CHAR), ']')")
+
+	_, err = TriggerDDL(suite.db, "sqlserver", &Person{})
+	suite.True(errors.Is(err, ErrUnsupportedOperation))
+}
+
+func (suite *PluginTestSuite) TestTriggersSkipSoftDeletes() {
+	suite.Require().NoError(CreateTriggers(suite.db, &Person{}))
+	defer func() {
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_create"`)
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_update"`)
+	}()
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	count := func() int64 {
+		var n int64
+		suite.Require().NoError(suite.db.Unscoped().Model(&PersonHistory{}).Where("object_id = ?", p.ID).Count(&n).Error)
+
+		return n
+	}
+	suite.Require().NoError(suite.db.Delete(&p).Error)
+	suite.EqualValues(1, count())
+
+	suite.Require().NoError(suite.db.Unscoped().Model(&p).Update("deleted_at", nil).Error)
+	suite.EqualValues(2, count())
+}
+
+func (suite *PluginTestSuite) TestTriggerAndPluginEntriesUnderMaxAge() {
+	suite.Require().NoError(CreateTriggers(suite.db, &Person{}))
+	defer func() {
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_create"`)
+		suite.db.Exec(`DROP TRIGGER IF EXISTS "people_history_update"`)
+	}()
+
+	err := suite.db.Exec("INSERT INTO people (first_name, last_name) VALUES (?, ?)", "John", "Doe").Error
+	suite.Require().NoError(err)
+
+	var p Person
+	suite.Require().NoError(suite.db.First(&p, "first_name = ?", "John").Error)
+
+	suite.Require().NoError(suite.db.Exec(`DROP TRIGGER "people_history_create"`).Error)
+	suite.Require().NoError(suite.db.Exec(`DROP TRIGGER "people_history_update"`).Error)
+	suite.Require().NoError(suite.db.Use(New(WithRetentionPolicy(RetentionPolicy{
+		Model:  &Person{},
+		MaxAge: time.Hour,
+	}))))
+	suite.Require().NoError(suite.db.Model(&p).Update("last_name", "Smith").Error)
+
+	var stored []string
+	err = suite.db.
+		Model(&PersonHistory{}).
+		Where("object_id = ?", p.ID).
+		Order("id").
+		Pluck("CAST(created_at AS TEXT)", &stored).
+		Error
+	suite.Require().NoError(err)
+	suite.Require().Len(stored, 2)
+
+	zones := make([]int, 0, len(stored))
+	for _, value := range stored {
+		t, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", value)
+		suite.Require().NoError(err, "the trigger and the plugin write times in the same layout")
+
+		_, offset := t.Zone()
+		zones = append(zones, offset)
+	}
+	suite.Equal(zones[0], zones[1])
+
+	results, err := Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.Zero(results[0].Deleted)
+
+	now := time.Now()
+	suite.db.Config.NowFunc = func() time.Time {
+		return now.Add(2 * time.Hour)
+	}
+
+	results, err = Prune(context.Background(), suite.db)
+	suite.Require().NoError(err)
+	suite.EqualValues(2, results[0].Deleted)
+}
+
+func (suite *PluginTestSuite) TestTriggerDDLModelConfig() {
+	plugin := New()
+	suite.Require().NoError(suite.db.Use(plugin))
+	suite.Require().NoError(plugin.Register(&Person{}, WithModelActions(ActionCreate)))
+
+	ddl, err := TriggerDDL(suite.db, DialectSQLite, &Person{})
+	suite.Require().NoError(err)
+	sql := strings.Join(ddl, ";\n")
+	suite.Contains(sql, `CREATE TRIGGER "people_history_create"`)
+	suite.Contains(sql, `DROP TRIGGER IF EXISTS "people_history_update"`)
+	suite.NotContains(sql, `CREATE TRIGGER "people_history_update"`)
+
+	suite.Require().NoError(plugin.Register(&Person{}, WithModelDisabled()))
+
+	ddl, err = TriggerDDL(suite.db, DialectPostgres, &Person{})
+	suite.Require().NoError(err)
+	suite.Equal([]string{
+		`DROP TRIGGER IF EXISTS "people_history_create" ON "people"`,
+		`DROP FUNCTION IF EXISTS "people_history_create"()`,
+		`DROP TRIGGER IF EXISTS "people_history_update" ON "people"`,
+		`DROP FUNCTION IF EXISTS "people_history_update"()`,
+	}, ddl)
+}
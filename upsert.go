@@ -0,0 +1,199 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ActionUpsert Action = "upsert"
+
+	existingRowsOptionKey = pluginName + ":existing_rows"
+	statementOptionKey    = pluginName + ":statement"
+)
+
+// beginStatement marks the statement of db as the one run by the caller. gorm copies the settings of a statement to
+// the statements saving its associations, which therefore carry the mark of their parent.
+func beginStatement(db *gorm.DB) {
+	if _, ok := db.Statement.Settings.Load(statementOptionKey); !ok {
+		db.Statement.Settings.Store(statementOptionKey, db.Statement)
+	}
+}
+
+func endStatement(db *gorm.DB) {
+	db.Statement.Settings.Delete(statementOptionKey)
+	db.Statement.Settings.Delete(existingRowsOptionKey)
+}
+
+// isAssociationStatement reports whether the statement of db was run by gorm to save the associations of the
+// records of another statement. gorm adds an ON CONFLICT clause to those statements, which are not upserts.
+func isAssociationStatement(db *gorm.DB) bool {
+	parent, ok := db.Statement.Settings.Load(statementOptionKey)
+
+	return ok && parent != db.Statement
+}
+
+// beforeUpsert looks up which of the records created with an ON CONFLICT clause already exist, so that their
+// history entries are recorded as updates, and the others as creates. It can only tell when the conflict target
+// is the primary key and the records carry their keys, e.g. client generated IDs. Otherwise, the entries are
+// recorded as ActionUpsert. The rows are looked up before the statement runs, so a row inserted concurrently in
+// between is recorded as created.
+func (p *Plugin) beforeUpsert(db *gorm.DB) {
+	db.Statement.Settings.Delete(existingRowsOptionKey)
+	if db.Error != nil || db.Statement.Schema == nil || IsDisabled(db) {
+		return
+	}
+
+	oc, ok := upsertConflict(db)
+	if !ok || !isPrimaryKeyConflict(db.Statement.Schema, oc) {
+		return
+	}
+
+	var (
+		values [][]interface{}
+		keys   []string
+	)
+	for _, v := range joinRows(db.Statement.ReflectValue) {
+		if _, ok := v.Interface().(Recordable); !ok {
+			return
+		}
+
+		pk, err := getPrimaryKeyValue(db, v)
+		if err != nil || pk.isZero {
+			return
+		}
+
+		key, ok := pk.value.(CompositeKey)
+		if !ok {
+			key = CompositeKey{pk.value}
+		}
+
+		values = append(values, key)
+		keys = append(keys, fmt.Sprintf("%v", pk.value))
+	}
+
+	if len(values) == 0 {
+		return
+	}
+
+	s := db.Statement.Schema
+	columns := make([]string, len(s.PrimaryFields))
+	for i, field := range s.PrimaryFields {
+		columns[i] = field.DBName
+	}
+
+	column, queryValues := schema.ToQueryValues(s.Table, columns, values)
+	existing := reflect.New(reflect.SliceOf(s.ModelType))
+	err := db.
+		Session(&gorm.Session{NewDB: true}).
+		Unscoped().
+		Model(reflect.New(s.ModelType).Interface()).
+		Where(clause.IN{Column: column, Values: queryValues}).
+		Find(existing.Interface()).
+		Error
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	found := make(map[string]bool, len(keys))
+	for _, v := range joinRows(existing.Elem()) {
+		pk, err := getPrimaryKeyValue(db, v)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		found[fmt.Sprintf("%v", pk.value)] = true
+	}
+
+	db.Statement.Settings.Store(existingRowsOptionKey, found)
+}
+
+// upsertAction returns the action of the history entry of the record identified by pk, created by the statement
+// of db, and whether no entry must be recorded because an existing row was left untouched.
+func upsertAction(db *gorm.DB, pk *primaryKeyField) (Action, bool) {
+	oc, ok := upsertConflict(db)
+	if !ok {
+		return ActionCreate, false
+	}
+
+	value, ok := db.Statement.Settings.Load(existingRowsOptionKey)
+	if !ok {
+		return ActionUpsert, false
+	}
+
+	if !value.(map[string]bool)[fmt.Sprintf("%v", pk.value)] {
+		return ActionCreate, false
+	}
+
+	if oc.DoNothing {
+		return "", true
+	}
+
+	return ActionUpdate, false
+}
+
+// reloadRecord loads the row of the record v identified by pk, which an upsert may have updated with only some of
+// the values of v.
+func reloadRecord(db *gorm.DB, v reflect.Value, pk *primaryKeyField) (reflect.Value, error) {
+	s := db.Statement.Schema
+	tx := db.Session(&gorm.Session{NewDB: true}).Unscoped()
+
+	v = reflect.Indirect(v)
+	for _, name := range pk.names {
+		field := s.LookUpField(name)
+		tx = tx.Where(fmt.Sprintf("%s = ?", db.Statement.Quote(field.DBName)), v.FieldByName(name).Interface())
+	}
+
+	row := reflect.New(s.ModelType)
+	if err := tx.First(row.Interface()).Error; err != nil {
+		return reflect.Value{}, fmt.Errorf("error loading upserted record: %w", err)
+	}
+
+	return row, nil
+}
+
+// upsertConflict returns the ON CONFLICT clause the caller added to the statement of db, ignoring the clauses gorm
+// adds to the statements saving associations.
+func upsertConflict(db *gorm.DB) (clause.OnConflict, bool) {
+	if isAssociationStatement(db) {
+		return clause.OnConflict{}, false
+	}
+
+	c, ok := db.Statement.Clauses[clause.OnConflict{}.Name()]
+	if !ok {
+		return clause.OnConflict{}, false
+	}
+
+	oc, ok := c.Expression.(clause.OnConflict)
+
+	return oc, ok
+}
+
+func isPrimaryKeyConflict(s *schema.Schema, oc clause.OnConflict) bool {
+	if oc.OnConstraint != "" || len(s.PrimaryFields) == 0 {
+		return false
+	}
+
+	if len(oc.Columns) == 0 {
+		return true
+	}
+
+	if len(oc.Columns) != len(s.PrimaryFields) {
+		return false
+	}
+
+	for _, column := range oc.Columns {
+		field := s.LookUpField(column.Name)
+		if field == nil || !field.PrimaryKey {
+			return false
+		}
+	}
+
+	return true
+}
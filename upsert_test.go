@@ -0,0 +1,73 @@
+package history
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (suite *PluginTestSuite) TestUpsert() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{Model: gorm.Model{ID: 100}, FirstName: "John"}
+	suite.Require().NoError(suite.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error)
+
+	p = Person{Model: gorm.Model{ID: 100}, FirstName: "Jane"}
+	suite.Require().NoError(suite.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error)
+
+	p = Person{Model: gorm.Model{ID: 100}, FirstName: "Ignored"}
+	suite.Require().NoError(suite.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&p).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", 100).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(ActionCreate, entries[0].Action)
+	suite.Equal(ActionUpdate, entries[1].Action)
+	suite.Equal("Jane", entries[1].FirstName)
+}
+
+func (suite *PluginTestSuite) TestUpsertWithoutKey() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal(ActionUpsert, entry.Action)
+}
+
+func (suite *PluginTestSuite) TestAssociationCreateIsNotUpsert() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{FirstName: "John", Address: &Address{Line1: "Line 1"}}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	var entry AddressHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.Address.ID).Error)
+	suite.Equal(ActionCreate, entry.Action)
+
+	p.Address = &Address{Line1: "Line 2"}
+	suite.Require().NoError(suite.db.Save(&p).Error)
+
+	var saved AddressHistory
+	suite.Require().NoError(suite.db.First(&saved, "object_id = ?", p.Address.ID).Error)
+	suite.Equal(ActionCreate, saved.Action)
+}
+
+func (suite *PluginTestSuite) TestUpsertSubsetRecordsStoredRow() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := Person{Model: gorm.Model{ID: 100}, FirstName: "John", LastName: "Doe"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	p = Person{Model: gorm.Model{ID: 100}, FirstName: "Jane", LastName: "Smith"}
+	upsert := clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"first_name"})}
+	suite.Require().NoError(suite.db.Clauses(upsert).Create(&p).Error)
+
+	var entries []PersonHistory
+	suite.Require().NoError(suite.db.Order("id asc").Find(&entries, "object_id = ?", 100).Error)
+	suite.Require().Len(entries, 2)
+	suite.Equal(ActionUpdate, entries[1].Action)
+	suite.Equal("Jane", entries[1].FirstName)
+	suite.Equal("Doe", entries[1].LastName)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 	"reflect"
 )
 
@@ -50,21 +51,45 @@ func getPrimaryKeyValue(db *gorm.DB, value reflect.Value) (*primaryKeyField, err
 	}
 
 	value = reflect.Indirect(value)
-	pkField := db.Statement.Schema.PrioritizedPrimaryField
-	if pkField == nil {
+	fields := db.Statement.Schema.PrimaryFields
+	if len(fields) == 0 {
 		return nil, fmt.Errorf("primary key field could not be determined for %T", value.Interface())
 	}
 
-	pkValue := value.FieldByName(pkField.Name)
+	pk := &primaryKeyField{isZero: true}
+	key := make(CompositeKey, len(fields))
+	for i, field := range fields {
+		fieldValue := value.FieldByName(field.Name)
 
-	isZero := pkValue.IsZero()
-	if v, ok := pkValue.Interface().(IsZeroer); ok {
-		isZero = v.IsZero()
+		isZero := fieldValue.IsZero()
+		if v, ok := fieldValue.Interface().(IsZeroer); ok {
+			isZero = v.IsZero()
+		}
+
+		pk.names = append(pk.names, field.Name)
+		pk.isZero = pk.isZero && isZero
+		key[i] = fieldValue.Interface()
+	}
+
+	pk.value = key[0]
+	if len(key) > 1 {
+		pk.value = key
+	}
+
+	return pk, nil
+}
+
+func lookUpColumn(s *schema.Schema, name string) (string, error) {
+	if tag, ok := fieldTags[name]; ok {
+		if field := taggedField(s, tag); field != nil && field.DBName != "" {
+			return field.DBName, nil
+		}
+	}
+
+	field := s.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return "", fmt.Errorf(`history model %s does not have field "%s"`, s.Name, name)
 	}
 
-	return &primaryKeyField{
-		name:   pkField.Name,
-		value:  pkValue.Interface(),
-		isZero: isZero,
-	}, nil
+	return field.DBName, nil
 }
@@ -104,6 +104,11 @@ func TestGetPrimaryKeyValue(t *testing.T) {
 		Name string
 	}
 
+	type EntityWithCompositePK struct {
+		OrderID uint `gorm:"primaryKey"`
+		LineNo  uint `gorm:"primaryKey"`
+	}
+
 	tests := []struct {
 		name     string
 		object   interface{}
@@ -120,7 +125,7 @@ func TestGetPrimaryKeyValue(t *testing.T) {
 				LastName:  "Doe",
 			},
 			expected: primaryKeyField{
-				name:   "ID",
+				names:  []string{"ID"},
 				value:  uint(444),
 				isZero: false,
 			},
@@ -135,7 +140,7 @@ func TestGetPrimaryKeyValue(t *testing.T) {
 				LastName:  "Doe",
 			},
 			expected: primaryKeyField{
-				name:   "ID",
+				names:  []string{"ID"},
 				value:  uint(444),
 				isZero: false,
 			},
@@ -154,11 +159,20 @@ func TestGetPrimaryKeyValue(t *testing.T) {
 				Name: "John",
 			},
 			expected: primaryKeyField{
-				name:   "ID",
+				names:  []string{"ID"},
 				value:  ID("zero"),
 				isZero: true,
 			},
 		},
+		{
+			name:   "object with composite PK",
+			object: EntityWithCompositePK{OrderID: 42, LineNo: 0},
+			expected: primaryKeyField{
+				names:  []string{"OrderID", "LineNo"},
+				value:  CompositeKey{uint(42), uint(0)},
+				isZero: false,
+			},
+		},
 		{
 			name:   "nil object",
 			object: nil,
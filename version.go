@@ -0,0 +1,320 @@
+package history
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	sequentialVersionWidth     = 10
+	defaultSequentialCacheSize = 1024
+	backfillBatchSize          = 1000
+)
+
+// SequentialVersion numbers the history entries of each object 1, 2, 3... (zero padded so versions keep
+// sorting as strings).
+//
+// The next number is computed from the greatest version stored for the object, read inside the transaction
+// of the statement being recorded, while holding a lock of the object. The last numbers assigned in the process
+// are cached too, for the objects whose entries are not committed yet, so versions are unique within a process.
+// Nothing serializes writers of different processes, e.g. a create has no existing row the database could lock,
+// and under repeatable read the greatest version is read from a snapshot. Add a unique index on
+// (object_id, version) so that the database rejects duplicates, and retry the statements failing on it. A rolled
+// back transaction leaves a gap in the sequence.
+type SequentialVersion struct {
+	mu        sync.Mutex
+	locks     map[string]*versionLock
+	last      map[string]*list.Element
+	lru       *list.List
+	cacheSize int
+}
+
+type (
+	SequentialVersionOption func(v *SequentialVersion)
+
+	versionLock struct {
+		sync.Mutex
+		refs int
+	}
+
+	lastVersion struct {
+		key string
+		n   uint64
+	}
+)
+
+func NewSequentialVersion(opts ...SequentialVersionOption) *SequentialVersion {
+	v := &SequentialVersion{
+		locks:     make(map[string]*versionLock),
+		last:      make(map[string]*list.Element),
+		lru:       list.New(),
+		cacheSize: defaultSequentialCacheSize,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// WithSequentialCacheSize sets how many objects SequentialVersion remembers the last assigned number of
+// (default 1024). It must be greater than the number of objects written concurrently by the process.
+func WithSequentialCacheSize(size int) SequentialVersionOption {
+	return func(v *SequentialVersion) {
+		if size > 0 {
+			v.cacheSize = size
+		}
+	}
+}
+
+func (v *SequentialVersion) Version(ctx *Context) (Version, error) {
+	key := fmt.Sprintf("%T:%v", historyModel(ctx.History()), ctx.ObjectID())
+
+	unlock := v.lock(key)
+	defer unlock()
+
+	latest, err := LatestVersion(ctx.DB(), ctx.Object(), ctx.ObjectID())
+	if err != nil {
+		return "", err
+	}
+
+	var n uint64
+	if latest != "" {
+		n, err = strconv.ParseUint(string(latest), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("version %q of %s is not sequential: %w", latest, key, err)
+		}
+	}
+
+	if last := v.lastVersion(key); last > n {
+		n = last
+	}
+
+	n++
+	v.setLastVersion(key, n)
+
+	return Version(fmt.Sprintf("%0*d", sequentialVersionWidth, n)), nil
+}
+
+// lock locks the object identified by key and returns the function unlocking it. The locks are dropped once no
+// one holds or waits for them.
+func (v *SequentialVersion) lock(key string) func() {
+	v.mu.Lock()
+	l, ok := v.locks[key]
+	if !ok {
+		l = &versionLock{}
+		v.locks[key] = l
+	}
+	l.refs++
+	v.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		v.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(v.locks, key)
+		}
+		v.mu.Unlock()
+	}
+}
+
+func (v *SequentialVersion) lastVersion(key string) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	el, ok := v.last[key]
+	if !ok {
+		return 0
+	}
+
+	v.lru.MoveToFront(el)
+
+	return el.Value.(*lastVersion).n
+}
+
+func (v *SequentialVersion) setLastVersion(key string, n uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if el, ok := v.last[key]; ok {
+		el.Value.(*lastVersion).n = n
+		v.lru.MoveToFront(el)
+
+		return
+	}
+
+	v.last[key] = v.lru.PushFront(&lastVersion{key: key, n: n})
+	for v.lru.Len() > v.cacheSize {
+		el := v.lru.Back()
+		v.lru.Remove(el)
+		delete(v.last, el.Value.(*lastVersion).key)
+	}
+}
+
+// BackfillVersions sets a ULID version generated from CreatedAt on every history entry of models which does not
+// have a version, e.g. the create entries recorded before ULIDVersion was configured WithCreateVersions. It returns
+// the number of entries updated. The models versioned by SequentialVersion by the plugin registered on db are
+// rejected, since their versions are numbers.
+func BackfillVersions(db *gorm.DB, models ...Recordable) (int64, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+	var (
+		total int64
+		seq   uint64
+	)
+	for _, model := range models {
+		if err := checkBackfillable(db, model); err != nil {
+			return total, err
+		}
+
+		n, err := backfillVersions(db, model, &seq)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// checkBackfillable returns ErrUnsupportedOperation when the plugin registered on db versions model with
+// SequentialVersion.
+func checkBackfillable(db *gorm.DB, model Recordable) error {
+	p, err := getPlugin(db)
+	if err != nil {
+		return nil
+	}
+
+	cfg, err := p.ModelConfig(model)
+	if err != nil {
+		return err
+	}
+
+	versionFunc := p.versionFunc
+	if cfg.VersionFunc != nil {
+		versionFunc = cfg.VersionFunc
+	}
+
+	// every method value of SequentialVersion.Version shares the same code pointer
+	if reflect.ValueOf(versionFunc).Pointer() == reflect.ValueOf((&SequentialVersion{}).Version).Pointer() {
+		return fmt.Errorf("not able to backfill sequential versions with ULIDs: %w", ErrUnsupportedOperation)
+	}
+
+	return nil
+}
+
+func backfillVersions(db *gorm.DB, model Recordable, seq *uint64) (int64, error) {
+	s, err := parseHistorySchema(db, model)
+	if err != nil {
+		return 0, err
+	}
+
+	versionCol, err := lookUpColumn(s, "Version")
+	if err != nil {
+		return 0, err
+	}
+
+	createdAtCol, err := lookUpColumn(s, "CreatedAt")
+	if err != nil {
+		return 0, err
+	}
+
+	pk := s.PrioritizedPrimaryField.DBName
+	version := db.Statement.Quote(versionCol)
+
+	var total int64
+	for {
+		type row struct {
+			id        interface{}
+			createdAt time.Time
+		}
+
+		rows, err := db.
+			Unscoped().
+			Model(reflect.New(s.ModelType).Interface()).
+			Select(fmt.Sprintf("%s, %s", db.Statement.Quote(pk), db.Statement.Quote(createdAtCol))).
+			Where(fmt.Sprintf("%s = '' OR %s IS NULL", version, version)).
+			Order(fmt.Sprintf("%s, %s", db.Statement.Quote(createdAtCol), db.Statement.Quote(pk))).
+			Limit(backfillBatchSize).
+			Rows()
+		if err != nil {
+			return total, err
+		}
+
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.createdAt); err != nil {
+				_ = rows.Close()
+
+				return total, err
+			}
+
+			batch = append(batch, r)
+		}
+
+		if err := rows.Close(); err != nil {
+			return total, err
+		}
+
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, r := range batch {
+				uid, err := backfillULID(r.createdAt, seq)
+				if err != nil {
+					return err
+				}
+
+				err = tx.
+					Unscoped().
+					Model(reflect.New(s.ModelType).Interface()).
+					Where(fmt.Sprintf("%s = ?", db.Statement.Quote(pk)), r.id).
+					UpdateColumn(versionCol, Version(uid.String())).
+					Error
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += int64(len(batch))
+	}
+}
+
+// backfillULID generates a ULID whose entropy is a sequence number instead of random bytes, so a backfilled version
+// sorts before the versions generated by ULIDVersion in the same millisecond.
+func backfillULID(t time.Time, seq *uint64) (ulid.ULID, error) {
+	var (
+		uid     ulid.ULID
+		entropy [10]byte
+	)
+
+	*seq++
+	binary.BigEndian.PutUint64(entropy[2:], *seq)
+
+	if err := uid.SetTime(ulid.Timestamp(t)); err != nil {
+		return uid, err
+	}
+
+	return uid, uid.SetEntropy(entropy[:])
+}
@@ -0,0 +1,197 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openFileDB opens a migrated SQLite database file using plugin, for the tests running concurrent statements,
+// which the shared in-memory database of the suite does not support.
+func openFileDB(t *testing.T, config *gorm.Config, plugin *Plugin) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s?_txlock=immediate&_busy_timeout=5000", filepath.Join(t.TempDir(), "history.db"))
+	db, err := gorm.Open(sqlite.Open(dsn), config)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(Person{}, PersonHistory{}))
+	require.NoError(t, db.Use(plugin))
+
+	return db
+}
+
+func TestSequentialVersion(t *testing.T) {
+	db := openFileDB(t, &gorm.Config{}, New(WithVersionFunc(NewSequentialVersion().Version)))
+
+	p := Person{FirstName: "John"}
+	require.NoError(t, db.Create(&p).Error)
+
+	p.FirstName = "Jane"
+	require.NoError(t, db.Save(&p).Error)
+
+	other := Person{FirstName: "Other"}
+	require.NoError(t, db.Create(&other).Error)
+
+	var versions []Version
+	err := db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Order("version").Pluck("version", &versions).Error
+	require.NoError(t, err)
+	require.Equal(t, []Version{"0000000001", "0000000002"}, versions)
+
+	err = db.Model(&PersonHistory{}).Where("object_id = ?", other.ID).Pluck("version", &versions).Error
+	require.NoError(t, err)
+	require.Equal(t, []Version{"0000000001"}, versions)
+}
+
+func TestSequentialVersionConcurrentUpdates(t *testing.T) {
+	db := openFileDB(t, &gorm.Config{}, New(WithVersionFunc(NewSequentialVersion().Version)))
+
+	p := Person{FirstName: "John"}
+	require.NoError(t, db.Create(&p).Error)
+
+	n := 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			np := p
+			np.FirstName = fmt.Sprintf("Jane %d", i)
+			errs <- db.Save(&np).Error
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var versions []string
+	err := db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Pluck("version", &versions).Error
+	require.NoError(t, err)
+	require.Len(t, versions, n+1)
+
+	sort.Strings(versions)
+	for i, version := range versions {
+		require.Equal(t, fmt.Sprintf("%010d", i+1), version)
+	}
+}
+
+func TestSequentialVersionWithoutTransactions(t *testing.T) {
+	version := NewSequentialVersion(WithSequentialCacheSize(1))
+	db := openFileDB(t, &gorm.Config{SkipDefaultTransaction: true}, New(WithVersionFunc(version.Version)))
+
+	p := Person{FirstName: "John"}
+	require.NoError(t, db.Create(&p).Error)
+
+	n := 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			np := p
+			np.FirstName = fmt.Sprintf("Jane %d", i)
+			errs <- db.Save(&np).Error
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var versions []string
+	err := db.Model(&PersonHistory{}).Where("object_id = ?", p.ID).Pluck("version", &versions).Error
+	require.NoError(t, err)
+	require.Len(t, versions, n+1)
+
+	sort.Strings(versions)
+	for i, version := range versions {
+		require.Equal(t, fmt.Sprintf("%010d", i+1), version)
+	}
+
+	other := Person{FirstName: "Other"}
+	require.NoError(t, db.Create(&other).Error)
+
+	p.FirstName = "John"
+	require.NoError(t, db.Save(&p).Error)
+
+	latest, err := LatestVersion(db, &Person{}, p.ID)
+	require.NoError(t, err)
+	require.Equal(t, Version(fmt.Sprintf("%010d", n+2)), latest)
+	require.Empty(t, version.locks)
+	require.Equal(t, 1, version.lru.Len())
+}
+
+func TestULIDVersionWithCreateVersions(t *testing.T) {
+	version := NewULIDVersion(WithCreateVersions())
+	v, err := version.Version(&Context{action: ActionCreate})
+	require.NoError(t, err)
+	require.NotZero(t, v)
+}
+
+func (suite *PluginTestSuite) TestBackfillVersions() {
+	suite.Require().NoError(suite.db.Use(New()))
+
+	p := suite.createPersonWithUpdates(2)
+	other := suite.createPersonWithUpdates(0)
+
+	n, err := BackfillVersions(suite.db, &Person{})
+	suite.Require().NoError(err)
+	suite.EqualValues(2, n)
+
+	var entries []PersonHistory
+	err = suite.db.Order("version asc").Find(&entries, "object_id = ?", p.ID).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 3)
+	suite.Equal(ActionCreate, entries[0].Action)
+
+	for _, entry := range entries {
+		suite.NotZero(entry.Version)
+	}
+
+	var versions []Version
+	err = suite.db.Model(&PersonHistory{}).Where("object_id = ?", other.ID).Pluck("version", &versions).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(versions, 1)
+	suite.NotZero(versions[0])
+
+	n, err = BackfillVersions(suite.db, &Person{})
+	suite.Require().NoError(err)
+	suite.Zero(n)
+}
+
+func (suite *PluginTestSuite) TestCreateVersions() {
+	version := NewULIDVersion(WithCreateVersions())
+	suite.Require().NoError(suite.db.Use(New(WithVersionFunc(version.Version))))
+
+	p := Person{FirstName: "John"}
+	suite.Require().NoError(suite.db.Create(&p).Error)
+
+	var entry PersonHistory
+	suite.Require().NoError(suite.db.First(&entry, "object_id = ?", p.ID).Error)
+	suite.Equal(ActionCreate, entry.Action)
+	suite.NotZero(entry.Version)
+}
+
+func (suite *PluginTestSuite) TestBackfillSequentialVersions() {
+	suite.Require().NoError(suite.db.Use(New(WithVersionFunc(NewSequentialVersion().Version))))
+
+	_, err := BackfillVersions(suite.db, &Person{})
+	suite.True(errors.Is(err, ErrUnsupportedOperation), err)
+}